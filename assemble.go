@@ -0,0 +1,220 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+// This file contains the row-pattern assembly solver.
+// A solution is built by picking one valid line per row, among the valid
+// lines that match the givens of the row.  The state of each column is
+// updated after each row, and a row is only accepted if all the columns
+// can still be completed, taking their givens into account.  The
+// uniqueness of the columns is checked when the board is complete.
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// assembleCheckInterval is the number of rows tried between two checks of
+// the context and the timeout
+const assembleCheckInterval = 1024
+
+// columnCompletable returns, for each position i of the column and each
+// prefix state, true if the column can be completed from position i.
+func columnCompletable(cells []Cell) [][]bool {
+	size := len(cells)
+	nStates := (size/2 + 1) * 6
+	ok := make([][]bool, size+1)
+	for i := range ok {
+		ok[i] = make([]bool, nStates)
+	}
+	// All the states reachable at the end are valid; other states are
+	// never reached.
+	for k := range ok[size] {
+		ok[size][k] = true
+	}
+	for i := size - 1; i >= 0; i-- {
+		for n0 := 0; n0 <= size/2; n0++ {
+			for last := 0; last < 2; last++ {
+				for run := 0; run < 3; run++ {
+					s := rangeState{n0, last, run}
+					for v := 0; v < 2; v++ {
+						if cells[i].Defined && cells[i].Value != v {
+							continue
+						}
+						if ns, valid := s.next(v, i, size); valid && ok[i+1][ns.index()] {
+							ok[i][s.index()] = true
+							break
+						}
+					}
+				}
+			}
+		}
+	}
+	return ok
+}
+
+// assemble enumerates the solutions of the board built from the valid row
+// patterns.  The visit function is called with the rows of each solution
+// (as bitmasks); the enumeration stops if it returns false.  The stop
+// function is called regularly; the enumeration is aborted if it returns an
+// error.
+func (b Takuzu) assemble(visit func(rows []uint64) bool, stop func() error) error {
+	size := b.Size
+	patterns := LinePatterns(size)
+	if patterns == nil {
+		return errors.Errorf("no line table for size %d", size)
+	}
+	all := uint64(1)<<uint(size) - 1
+
+	// The forced cells are set first, to reduce the number of candidates
+	b = b.Clone()
+	if _, err := b.Propagate(); err != nil {
+		return nil // No solution
+	}
+
+	// Candidate patterns of each row, matching the givens
+	candidates := make([][]uint64, size)
+	for l := range b.Board {
+		bits, defined := rangeBits(b.Board[l])
+		for _, p := range patterns {
+			if p&defined == bits {
+				candidates[l] = append(candidates[l], p)
+			}
+		}
+		if len(candidates[l]) == 0 {
+			return nil // No solution
+		}
+	}
+
+	completable := make([][][]bool, size)
+	for c := range completable {
+		completable[c] = columnCompletable(b.GetColumn(c))
+		if !completable[c][0][rangeState{}.index()] {
+			return nil // No solution
+		}
+	}
+
+	rows := make([]uint64, size)
+	used := make(map[uint64]bool)
+	columns := make([]rangeState, size)
+	var tries int
+	var err error
+
+	var fill func(r int) bool
+	fill = func(r int) bool {
+		if r == size {
+			if !columnsDistinct(rows, size) {
+				return true
+			}
+			return visit(rows)
+		}
+
+		// Columns that cannot get a 0 or a 1 in this row
+		var no0, no1 uint64
+		for c, cs := range columns {
+			bit := uint64(1) << uint(c)
+			if ns, ok := cs.next(0, r, size); !ok || !completable[c][r+1][ns.index()] {
+				no0 |= bit
+			}
+			if ns, ok := cs.next(1, r, size); !ok || !completable[c][r+1][ns.index()] {
+				no1 |= bit
+			}
+		}
+
+		saved := make([]rangeState, size)
+		copy(saved, columns)
+		for _, p := range candidates[r] {
+			if p&no1 != 0 || ^p&no0&all != 0 || used[p] {
+				continue
+			}
+			tries++
+			if tries%assembleCheckInterval == 0 {
+				if err = stop(); err != nil {
+					return false
+				}
+			}
+			rows[r] = p
+			used[p] = true
+			for c := range columns {
+				columns[c], _ = columns[c].next(int(p>>uint(c)&1), r, size)
+			}
+			more := fill(r + 1)
+			copy(columns, saved)
+			delete(used, p)
+			if !more {
+				return false
+			}
+		}
+		return true
+	}
+	fill(0)
+	return err
+}
+
+// assembleStop returns the stop function for the assembly solver options
+func assembleStop(opts SolveOptions) func() error {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	startTime := time.Now()
+	return func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if opts.Timeout > 0 && time.Since(startTime) > opts.Timeout {
+			return errors.New("timeout")
+		}
+		return nil
+	}
+}
+
+// TrySolveAssemble solves the takuzu by assembling valid row patterns.
+// It follows the semantics of TrySolveWithOptions: if allSolutions is not
+// nil, all the solutions (up to opts.MaxSolutions) are looked for.  The
+// Propagate and Heuristic options are ignored, and the board is not
+// updated.  The board size must not be larger than MaxLinePatternSize.
+func (b Takuzu) TrySolveAssemble(allSolutions *[]Takuzu, opts SolveOptions) (*Takuzu, error) {
+	var first *Takuzu
+	visit := func(rows []uint64) bool {
+		tak := New(b.Size)
+		for l := range tak.Board {
+			for c := range tak.Board[l] {
+				tak.Board[l][c].Set(int(rows[l] >> uint(c) & 1))
+			}
+		}
+		if first == nil {
+			first = &tak
+		}
+		if allSolutions == nil {
+			return false
+		}
+		*allSolutions = append(*allSolutions, tak)
+		return opts.MaxSolutions <= 0 || len(*allSolutions) < opts.MaxSolutions
+	}
+	if err := b.assemble(visit, assembleStop(opts)); err != nil {
+		return first, err
+	}
+	if first == nil {
+		return nil, ErrNoSolution
+	}
+	return first, nil
+}
+
+// CountSolutions returns the number of solutions of the takuzu, up to
+// opts.MaxSolutions (0 means no limit), using the row-pattern assembly
+// solver.  The boards of the solutions are not built.
+// The board size must not be larger than MaxLinePatternSize.
+func (b Takuzu) CountSolutions(opts SolveOptions) (int, error) {
+	n := 0
+	visit := func([]uint64) bool {
+		n++
+		return opts.MaxSolutions <= 0 || n < opts.MaxSolutions
+	}
+	err := b.assemble(visit, assembleStop(opts))
+	return n, err
+}
@@ -0,0 +1,160 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+import (
+	"sort"
+	"testing"
+)
+
+// testBuildOptions returns the options of a reproducible puzzle
+func testBuildOptions(size int, seed int64) BuildOptions {
+	return BuildOptions{
+		Size:     size,
+		MinRatio: DefaultMinRatio,
+		MaxRatio: DefaultMaxRatio,
+		Seed:     seed,
+	}
+}
+
+// testBoards returns puzzles with a unique solution, some of the same
+// puzzles with half of their given cells removed, and a few other boards
+func testBoards(t testing.TB) []Takuzu {
+	boards := []Takuzu{New(4)}
+	for _, size := range []int{6, 8, 10} {
+		for seed := int64(1); seed <= 3; seed++ {
+			tak, err := NewRandomTakuzuWithOptions(testBuildOptions(size, seed))
+			if err != nil {
+				t.Fatal(err)
+			}
+			boards = append(boards, *tak)
+			if size == 6 {
+				// Larger sparse boards have too many solutions
+				boards = append(boards, sparseBoard(*tak))
+			}
+		}
+	}
+	for _, s := range []string{
+		"1.0.............",
+		"0.....01.1.1.1..", // No solution
+		"111.............", // Invalid
+	} {
+		tak, err := NewFromString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		boards = append(boards, *tak)
+	}
+	return boards
+}
+
+// sparseBoard returns a copy of the board with every other given cell
+// removed
+func sparseBoard(b Takuzu) Takuzu {
+	sparse := b.Clone()
+	n := 0
+	for l := range sparse.Board {
+		for c := range sparse.Board[l] {
+			if sparse.Board[l][c].Defined {
+				if n%2 == 1 {
+					sparse.Board[l][c].Defined = false
+				}
+				n++
+			}
+		}
+	}
+	return sparse
+}
+
+// solutionStrings returns the sorted strings of the solutions
+func solutionStrings(solutions []Takuzu) []string {
+	s := make([]string, len(solutions))
+	for i, sol := range solutions {
+		s[i] = sol.ToString()
+	}
+	sort.Strings(s)
+	return s
+}
+
+func TestAssembleMatchesRecurse(t *testing.T) {
+	for _, b := range testBoards(t) {
+		want := &[]Takuzu{}
+		b.Clone().TrySolveWithOptions(want, SolveOptions{})
+		wantStrings := solutionStrings(*want)
+
+		got := &[]Takuzu{}
+		first, err := b.TrySolveAssemble(got, SolveOptions{})
+		gotStrings := solutionStrings(*got)
+		if len(gotStrings) != len(wantStrings) {
+			t.Errorf("%s: TrySolveAssemble found %d solutions, want %d",
+				b.ToString(), len(gotStrings), len(wantStrings))
+			continue
+		}
+		for i := range gotStrings {
+			if gotStrings[i] != wantStrings[i] {
+				t.Errorf("%s: solution %s not found", b.ToString(), wantStrings[i])
+			}
+		}
+		if len(wantStrings) == 0 && (err == nil || first != nil) {
+			t.Errorf("%s: TrySolveAssemble: no error without solution", b.ToString())
+		}
+		if len(wantStrings) > 0 && (err != nil || first == nil) {
+			t.Errorf("%s: TrySolveAssemble: %v", b.ToString(), err)
+		}
+
+		n, err := b.CountSolutions(SolveOptions{})
+		if err != nil || n != len(wantStrings) {
+			t.Errorf("%s: CountSolutions returned %d (%v), want %d",
+				b.ToString(), n, err, len(wantStrings))
+		}
+
+		// Limited searches
+		if len(wantStrings) > 1 {
+			got = &[]Takuzu{}
+			if _, err := b.TrySolveAssemble(got, SolveOptions{MaxSolutions: 2}); err != nil || len(*got) != 2 {
+				t.Errorf("%s: TrySolveAssemble found %d solutions (%v), want 2",
+					b.ToString(), len(*got), err)
+			}
+			if n, err := b.CountSolutions(SolveOptions{MaxSolutions: 2}); err != nil || n != 2 {
+				t.Errorf("%s: CountSolutions returned %d (%v), want 2", b.ToString(), n, err)
+			}
+		}
+	}
+}
+
+// benchmarkBoard returns a sparse 8x8 board with 6406 solutions
+func benchmarkBoard(b *testing.B) Takuzu {
+	tak, err := NewRandomTakuzuWithOptions(testBuildOptions(8, 3))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return sparseBoard(*tak)
+}
+
+func BenchmarkSolveRecurseAll(b *testing.B) {
+	tak := benchmarkBoard(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		allSol := &[]Takuzu{}
+		tak.Clone().TrySolveRecurse(allSol, 0)
+	}
+}
+
+func BenchmarkSolveAssembleAll(b *testing.B) {
+	tak := benchmarkBoard(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		allSol := &[]Takuzu{}
+		tak.TrySolveAssemble(allSol, SolveOptions{})
+	}
+}
+
+func BenchmarkCountSolutions(b *testing.B) {
+	tak := benchmarkBoard(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tak.CountSolutions(SolveOptions{})
+	}
+}
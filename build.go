@@ -8,9 +8,11 @@ package takuzu
 // puzzle.
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"math/rand"
+	"sort"
 	"time"
 
 	"github.com/pkg/errors"
@@ -20,13 +22,63 @@ type buildTakuzuOptions struct {
 	size                                  int
 	minRatio, maxRatio                    int
 	simple                                bool
+	rowPatterns                           bool
 	buildBoardTimeout, reduceBoardTimeout time.Duration
+	rng                                   randSource
+	ctx                                   context.Context
+}
+
+// BuildOptions contains the options used to build a new puzzle
+type BuildOptions struct {
+	Size int
+	// Simple requests a puzzle that can be solved with trivial methods
+	Simple bool
+	// MinRatio and MaxRatio are the percentages of empty cells (see
+	// NewRandomTakuzu)
+	MinRatio, MaxRatio int
+	// BuildTimeout and ReduceTimeout are the resolution timeouts
+	BuildTimeout, ReduceTimeout time.Duration
+	// RowPatterns builds the puzzle from a random complete board,
+	// assembled row by row from the table of the valid lines (larger
+	// boards are filled by the trail-based solver).  The empty cell ratios
+	// are not used.
+	RowPatterns bool
+	// Seed initializes the random generator; 0 means a random seed.
+	// With the same seed, the same puzzle is built unless a timeout occurs.
+	Seed int64
+	// ID identifies the builder in the log messages
+	ID string
+	// Context can be used to cancel the build
+	Context context.Context
+}
+
+// Default empty cell ratios of the gotak builder
+const (
+	DefaultMinRatio = 55
+	DefaultMaxRatio = 62
+)
+
+// randSource is a source of random numbers
+type randSource interface {
+	Intn(n int) int
+}
+
+// globalRand uses the default source of the math/rand package
+type globalRand struct{}
+
+func (globalRand) Intn(n int) int {
+	return rand.Intn(n)
 }
 
 // ReduceBoard randomly removes as many numbers as possible from the
 // takuzu board and returns a pointer to the new board.
 // The initial takuzu might be modified.
 func (tak Takuzu) ReduceBoard(trivial bool, wid string, buildBoardTimeout, reduceBoardTimeout time.Duration) (*Takuzu, error) {
+	return tak.reduceBoard(context.Background(), trivial, wid, globalRand{},
+		buildBoardTimeout, reduceBoardTimeout)
+}
+
+func (tak Takuzu) reduceBoard(ctx context.Context, trivial bool, wid string, rng randSource, buildBoardTimeout, reduceBoardTimeout time.Duration) (*Takuzu, error) {
 
 	size := tak.Size
 
@@ -35,9 +87,23 @@ func (tak Takuzu) ReduceBoard(trivial bool, wid string, buildBoardTimeout, reduc
 		log.Printf("[%v]ReduceBoard: Checking for all grid solutions...", wid)
 	}
 
+	// The trail-based solver is used when the board is not too large
+	trail := size <= MaxTrailSolverSize
+	assemble := LinePatterns(size) != nil
+
 	allSol := &[]Takuzu{}
-	_, err := tak.Clone().TrySolveRecurse(allSol, buildBoardTimeout)
+	var err error
+	if trail {
+		_, err = tak.TrySolveTrail(allSol,
+			SolveOptions{Timeout: buildBoardTimeout, Context: ctx})
+	} else {
+		_, err = tak.Clone().TrySolveWithOptions(allSol,
+			SolveOptions{Timeout: buildBoardTimeout, Context: ctx})
+	}
 	ns := len(*allSol)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 	if err != nil && errors.Cause(err).Error() == "timeout" {
 		if verbosity > 0 {
 			log.Printf("[%v]ReduceBoard: There was a timeout (%d resolution(s) found).", wid, ns)
@@ -58,7 +124,12 @@ func (tak Takuzu) ReduceBoard(trivial bool, wid string, buildBoardTimeout, reduc
 	if ns == 0 {
 		return nil, err
 	} else if ns > 1 {
-		tak = (*allSol)[rand.Intn(ns)]
+		// The solutions are sorted so that the choice only depends on
+		// the random source.
+		sort.Slice(*allSol, func(i, j int) bool {
+			return (*allSol)[i].ToString() < (*allSol)[j].ToString()
+		})
+		tak = (*allSol)[rng.Intn(ns)]
 		if verbosity > 0 {
 			log.Printf("[%v]ReduceBoard: Warning: there are %d solutions.", wid, ns)
 			log.Printf("[%v]ReduceBoard: Picking one randomly.", wid)
@@ -99,17 +170,41 @@ func (tak Takuzu) ReduceBoard(trivial bool, wid string, buildBoardTimeout, reduc
 	}
 
 	for ; n > 0; n-- {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		var rollback bool
-		i := rand.Intn(n)
+		i := rng.Intn(n)
 		fields[i].Defined = false
 		if trivial {
 			full, err := tak.Clone().TrySolveTrivial()
 			if err != nil || !full {
 				rollback = true
 			}
+		} else if assemble {
+			// The row-pattern assembly solver is much faster to
+			// count the solutions of sparse boards
+			ns, err := tak.CountSolutions(SolveOptions{Timeout: reduceBoardTimeout, MaxSolutions: 2, Context: ctx})
+			if err != nil || ns != 1 {
+				rollback = true
+			}
+		} else if trail {
+			// The board had a single solution, so another solution
+			// would have the other value in this cell: we only have
+			// to check that there is no solution with it.
+			v := fields[i].Value
+			fields[i].Set(1 - v)
+			_, err := tak.TrySolveTrail(nil,
+				SolveOptions{Timeout: reduceBoardTimeout, Context: ctx})
+			if err == nil || errors.Cause(err) != ErrNoSolution {
+				rollback = true
+			}
+			fields[i].Value = v
+			fields[i].Defined = false
 		} else {
 			allSol = &[]Takuzu{}
-			_, err := tak.Clone().TrySolveRecurse(allSol, reduceBoardTimeout)
+			_, err := tak.Clone().TrySolveWithOptions(allSol,
+				SolveOptions{Timeout: reduceBoardTimeout, MaxSolutions: 2, Context: ctx})
 			if err != nil || len(*allSol) != 1 {
 				rollback = true
 			}
@@ -149,19 +244,42 @@ func newRandomTakuzu(wid string, buildOpts buildTakuzuOptions) (*Takuzu, error)
 	reduceBoardTimeout := buildOpts.reduceBoardTimeout
 	minRatio := buildOpts.minRatio
 	maxRatio := buildOpts.maxRatio
+	rng := buildOpts.rng
+
+	if buildOpts.rowPatterns {
+		if verbosity > 0 {
+			log.Printf("[%v]NewRandomTakuzu: Building complete board (%dx%[2]d)...", wid, size)
+		}
+		tak := randomSolution(size, rng)
+		if tak == nil {
+			// No table of valid lines for this size
+			tak = trailRandomSolution(buildOpts.ctx, size, rng)
+		}
+		if tak == nil {
+			return nil, errors.New("could not build a complete board")
+		}
+		return tak.reduceBoard(buildOpts.ctx, easy, wid, rng,
+			buildBoardTimeout, reduceBoardTimeout)
+	}
 
 	tak := New(size)
 	n := size * size
-	fields := make([]*Cell, n)
+	fields := make([]Position, n)
 
 	i := 0
 	for l := range tak.Board {
 		for c := range tak.Board[l] {
-			fields[i] = &tak.Board[l][c]
+			fields[i] = Position{Line: l, Col: c}
 			i++
 		}
 	}
 
+	// The board is checked incrementally when it is not too large
+	set, validate := tak.Set, tak.Validate
+	if st, err := NewBoardState(&tak); err == nil {
+		set, validate = st.Set, st.Validate
+	}
+
 	if verbosity > 0 {
 		log.Printf("[%v]NewRandomTakuzu: Filling new board (%dx%[2]d)...", wid, size)
 	}
@@ -171,19 +289,23 @@ func newRandomTakuzu(wid string, buildOpts buildTakuzuOptions) (*Takuzu, error)
 	// #1. Loop until the ratio of empty cells is less than minRatio% (e.g. 55%)
 
 	for n > size*size*minRatio/100 {
-		i := rand.Intn(n)
-		fields[i].Defined = true
-		fields[i].Value = rand.Intn(2)
+		if err := buildOpts.ctx.Err(); err != nil {
+			return nil, err
+		}
+		i := rng.Intn(n)
+		p := fields[i]
+		value := rng.Intn(2)
+		set(p.Line, p.Col, value)
 
 		var err error
 
-		if _, err = tak.Validate(); err != nil {
+		if _, err = validate(); err != nil {
 			if verbosity > 1 {
-				log.Printf("[%v]NewRandomTakuzu: Could not set cell value to %v", wid, fields[i].Value)
+				log.Printf("[%v]NewRandomTakuzu: Could not set cell value to %v", wid, value)
 			}
 		} else if _, err = tak.Clone().TrySolveTrivial(); err != nil {
 			if verbosity > 1 {
-				log.Printf("[%v]NewRandomTakuzu: Trivial checks: Could not set cell value to %v", wid, fields[i].Value)
+				log.Printf("[%v]NewRandomTakuzu: Trivial checks: Could not set cell value to %v", wid, value)
 			}
 		}
 
@@ -194,8 +316,7 @@ func newRandomTakuzu(wid string, buildOpts buildTakuzuOptions) (*Takuzu, error)
 		}
 
 		// If any of the above checks fails, we roll back
-		fields[i].Defined = false
-		fields[i].Value = 0 // Let's reset but it is useless
+		set(p.Line, p.Col, -1)
 
 		// Safety check to avoid deadlock on bad boards
 		nop++
@@ -229,7 +350,11 @@ func newRandomTakuzu(wid string, buildOpts buildTakuzuOptions) (*Takuzu, error)
 			break
 		}
 		var err error
-		ptak, err = tak.ReduceBoard(easy, wid, buildBoardTimeout, reduceBoardTimeout)
+		ptak, err = tak.reduceBoard(buildOpts.ctx, easy, wid, rng,
+			buildBoardTimeout, reduceBoardTimeout)
+		if err := buildOpts.ctx.Err(); err != nil {
+			return nil, err
+		}
 		if err != nil && errors.Cause(err).Error() == "timeout" {
 			break
 		}
@@ -243,7 +368,7 @@ func newRandomTakuzu(wid string, buildOpts buildTakuzuOptions) (*Takuzu, error)
 		if inc == 0 {
 			inc = 1
 		}
-		tak.removeRandomCell(inc)
+		tak.removeRandomCell(rng, inc)
 		removed += inc
 		if verbosity > 1 {
 			log.Printf("[%v]NewRandomTakuzu: Removed %d numbers", wid, removed)
@@ -265,6 +390,21 @@ func newRandomTakuzu(wid string, buildOpts buildTakuzuOptions) (*Takuzu, error)
 
 // NewRandomTakuzu creates a new Takuzu board with a given size
 func NewRandomTakuzu(size int, simple bool, wid string, buildBoardTimeout, reduceBoardTimeout time.Duration, minRatio, maxRatio int) (*Takuzu, error) {
+	return NewRandomTakuzuWithOptions(BuildOptions{
+		Size:          size,
+		Simple:        simple,
+		MinRatio:      minRatio,
+		MaxRatio:      maxRatio,
+		BuildTimeout:  buildBoardTimeout,
+		ReduceTimeout: reduceBoardTimeout,
+		ID:            wid,
+	})
+}
+
+// NewRandomTakuzuWithOptions creates a new Takuzu board.
+// If the context is canceled, the context error is returned.
+func NewRandomTakuzuWithOptions(opts BuildOptions) (*Takuzu, error) {
+	size := opts.Size
 	if size%2 != 0 {
 		return nil, errors.New("board size should be an even value")
 	}
@@ -273,9 +413,14 @@ func NewRandomTakuzu(size int, simple bool, wid string, buildBoardTimeout, reduc
 		return nil, errors.New("board size is too small")
 	}
 
+	if opts.RowPatterns && size > MaxTrailSolverSize {
+		return nil, errors.Errorf("board size is too large for row patterns (maximum %d)", MaxTrailSolverSize)
+	}
+
 	// minRatio : percentage (1-100) of empty cells when creating a new board
 	// If the board is wrong the cells will be removed until we reach maxRatio
 
+	minRatio, maxRatio := opts.MinRatio, opts.MaxRatio
 	if minRatio < 40 {
 		minRatio = 40
 	}
@@ -291,25 +436,37 @@ func NewRandomTakuzu(size int, simple bool, wid string, buildBoardTimeout, reduc
 		size:               size,
 		minRatio:           minRatio,
 		maxRatio:           maxRatio,
-		simple:             simple,
-		buildBoardTimeout:  buildBoardTimeout,
-		reduceBoardTimeout: reduceBoardTimeout,
+		simple:             opts.Simple,
+		rowPatterns:        opts.RowPatterns,
+		buildBoardTimeout:  opts.BuildTimeout,
+		reduceBoardTimeout: opts.ReduceTimeout,
+		rng:                globalRand{},
+		ctx:                opts.Context,
+	}
+	if opts.Seed != 0 {
+		buildOptions.rng = rand.New(rand.NewSource(opts.Seed))
+	}
+	if buildOptions.ctx == nil {
+		buildOptions.ctx = context.Background()
 	}
 
 	var takP *Takuzu
 
 	for {
 		var err error
-		takP, err = newRandomTakuzu(wid, buildOptions)
+		takP, err = newRandomTakuzu(opts.ID, buildOptions)
 		if err == nil {
 			break
 		}
+		if ctxErr := buildOptions.ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
 	}
 
 	return takP, nil
 }
 
-func (tak Takuzu) removeRandomCell(number int) {
+func (tak Takuzu) removeRandomCell(rng randSource, number int) {
 	size := tak.Size
 	fields := make([]*Cell, size*size)
 	n := 0
@@ -325,7 +482,7 @@ func (tak Takuzu) removeRandomCell(number int) {
 		if n == 0 {
 			return
 		}
-		fields[rand.Intn(n)].Defined = false
+		fields[rng.Intn(n)].Defined = false
 		fields = append(fields[:i], fields[i+1:]...)
 		n--
 	}
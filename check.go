@@ -0,0 +1,96 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+// This file contains the functions used to check a player's board against
+// the solution of the puzzle.
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// StateCheck is the result of the check of a player's board
+type StateCheck struct {
+	// RuleError is the first rule violation of the board, if any
+	RuleError error
+	// Unique is true if the puzzle has a single solution.  The wrong cells
+	// can only be computed if the solution is unique.
+	Unique bool
+	// WrongCells contains the filled cells that do not match the solution
+	WrongCells []Position
+	// Consistent is true if the board can still be completed, i.e. if it
+	// has at least one valid completion.
+	Consistent bool
+	// Complete is true if the board is complete and correct
+	Complete bool
+}
+
+// CheckState compares a player's board (state) with the solution of the
+// initial puzzle.  The given cells of the puzzle must be set in the state.
+func CheckState(puzzle, state Takuzu, timeout time.Duration) (*StateCheck, error) {
+	return CheckStateWithOptions(puzzle, state, SolveOptions{Timeout: timeout})
+}
+
+// CheckStateWithOptions works like CheckState, using the given solver
+// options.  The MaxSolutions option is ignored.
+func CheckStateWithOptions(puzzle, state Takuzu, opts SolveOptions) (*StateCheck, error) {
+	if puzzle.Size != state.Size {
+		return nil, errors.New("sizes do not match")
+	}
+	g := NewGame(puzzle)
+	g.SetState(state)
+	for l := range puzzle.Board {
+		for c := range puzzle.Board[l] {
+			if g.Given(l, c) && !cellsMatch(puzzle.Board[l][c], state.Board[l][c], false) {
+				return nil, errors.New("the board does not match the puzzle")
+			}
+		}
+	}
+	return g.CheckWithOptions(opts)
+}
+
+// Check compares the current board with the solution of the puzzle.
+// The wrong cells can only be reported if the puzzle has a unique solution.
+// An error is returned if the puzzle has no solution or on timeout.
+func (g *Game) Check(timeout time.Duration) (*StateCheck, error) {
+	return g.CheckWithOptions(SolveOptions{Timeout: timeout})
+}
+
+// CheckWithOptions works like Check, using the given solver options.
+// The MaxSolutions option is ignored.
+func (g *Game) CheckWithOptions(opts SolveOptions) (*StateCheck, error) {
+	res := &StateCheck{}
+
+	full, err := g.State.Validate()
+	res.RuleError = err
+	res.Complete = full && err == nil
+
+	sol, err := g.SolutionWithOptions(opts)
+	if err == nil {
+		res.Unique = true
+		res.WrongCells = BoardsDiff(&g.State, sol, true)
+		// With a unique solution, the board can only be completed if
+		// all its cells match the solution.
+		res.Consistent = res.RuleError == nil && len(res.WrongCells) == 0
+		return res, nil
+	}
+	if isAbort(err) || errors.Cause(err) == ErrNoSolution {
+		return nil, err
+	}
+
+	// The puzzle has several solutions: look for a completion of the
+	// current board.
+	if res.RuleError == nil {
+		opts.MaxSolutions = 0
+		sol, err := g.State.Clone().TrySolveWithOptions(nil, opts)
+		if err != nil && isAbort(err) {
+			return nil, err
+		}
+		res.Consistent = err == nil && sol != nil
+	}
+	return res, nil
+}
@@ -0,0 +1,94 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestCheckState(t *testing.T) {
+	puzzle, err := NewFromString(testPuzzle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		state      string
+		wrongCells []Position
+		consistent bool
+		complete   bool
+		ruleError  bool
+	}{
+		{testPuzzle, nil, true, false, false},
+		{testSolution, nil, true, true, false},
+		// Wrong moves at [0,0], then at [1,0]
+		{"1" + testPuzzle[1:], []Position{{0, 0}}, false, false, false},
+		{"1" + testPuzzle[1:6] + "0" + testPuzzle[7:], []Position{{0, 0}, {1, 0}}, false, false, false},
+		// Wrong move breaking the rules
+		{"00" + testPuzzle[2:], []Position{{0, 1}}, false, false, true},
+		// Complete board with wrong cells
+		{"100110" + testSolution[6:], []Position{{0, 0}, {0, 1}}, false, false, true},
+	}
+	for _, tt := range tests {
+		state, err := NewFromString(tt.state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := CheckState(*puzzle, *state, 0)
+		if err != nil {
+			t.Errorf("%s: %v", tt.state, err)
+			continue
+		}
+		if !res.Unique {
+			t.Errorf("%s: the solution is not unique", tt.state)
+		}
+		if !reflect.DeepEqual(res.WrongCells, tt.wrongCells) {
+			t.Errorf("%s: wrong cells %v, want %v", tt.state, res.WrongCells, tt.wrongCells)
+		}
+		if res.Consistent != tt.consistent {
+			t.Errorf("%s: Consistent is %v", tt.state, res.Consistent)
+		}
+		if res.Complete != tt.complete {
+			t.Errorf("%s: Complete is %v", tt.state, res.Complete)
+		}
+		if (res.RuleError != nil) != tt.ruleError {
+			t.Errorf("%s: rule error %v", tt.state, res.RuleError)
+		}
+	}
+
+	// The given cells must not be changed
+	state, _ := NewFromString("..1.....0.............0.1....1.....1")
+	if _, err := CheckState(*puzzle, *state, 0); err == nil {
+		t.Error("no error for a changed given cell")
+	}
+
+	// Several solutions: the wrong cells are unknown
+	multi, _ := NewFromString("1.0.............")
+	for s, consistent := range map[string]bool{
+		"110.............": true,
+		"1.0.11....0.....": false, // No valid completion
+	} {
+		state, _ := NewFromString(s)
+		res, err := CheckState(*multi, *state, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Unique || res.WrongCells != nil || res.RuleError != nil {
+			t.Errorf("%s: unexpected result %+v", s, res)
+		}
+		if res.Consistent != consistent {
+			t.Errorf("%s: Consistent is %v, want %v", s, res.Consistent, consistent)
+		}
+	}
+
+	// No solution
+	none, _ := NewFromString("0.....01.1.1.1..")
+	if _, err := CheckState(*none, *none, 0); errors.Cause(err) != ErrNoSolution {
+		t.Errorf("CheckState returned %v, want %v", err, ErrNoSolution)
+	}
+}
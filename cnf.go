@@ -0,0 +1,276 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+// This file contains the SAT backend: the board is encoded in CNF and solved
+// with the built-in CDCL solver, or exported in DIMACS format.
+//
+// The cell (l, c) is the variable l*size+c+1, which is true for the value 1.
+// - No triples: for 3 consecutive cells, (a∨b∨c) and (¬a∨¬b∨¬c)
+// - Balance: exactly size/2 cells are 1 in each range, with sequential
+//   counters (at most size/2 1s and at most size/2 0s)
+// - No duplicates: for each pair of ranges of the same orientation, one
+//   auxiliary variable per position implies that the cells differ, and at
+//   least one of these variables is true.
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// cnf is a formula in conjunctive normal form, with DIMACS literals
+type cnf struct {
+	nVars   int
+	clauses [][]int
+}
+
+func (f *cnf) newVar() int {
+	f.nVars++
+	return f.nVars
+}
+
+func (f *cnf) add(lits ...int) {
+	f.clauses = append(f.clauses, lits)
+}
+
+// atMost adds the clauses for "at most k literals are true", using a
+// sequential counter
+func (f *cnf) atMost(lits []int, k int) {
+	n := len(lits)
+	if k >= n {
+		return
+	}
+	if k == 0 {
+		for _, x := range lits {
+			f.add(-x)
+		}
+		return
+	}
+
+	// s[i][j] is true if at least j+1 of the literals 0..i are true
+	s := make([][]int, n-1)
+	for i := range s {
+		s[i] = make([]int, k)
+		for j := range s[i] {
+			s[i][j] = f.newVar()
+		}
+	}
+	f.add(-lits[0], s[0][0])
+	for j := 1; j < k; j++ {
+		f.add(-s[0][j])
+	}
+	for i := 1; i < n-1; i++ {
+		f.add(-lits[i], s[i][0])
+		f.add(-s[i-1][0], s[i][0])
+		for j := 1; j < k; j++ {
+			f.add(-lits[i], -s[i-1][j-1], s[i][j])
+			f.add(-s[i-1][j], s[i][j])
+		}
+		f.add(-lits[i], -s[i-1][k-1])
+	}
+	f.add(-lits[n-1], -s[n-2][k-1])
+}
+
+// different adds the clauses for "the ranges a and b differ"
+func (f *cnf) different(a, b []int) {
+	diff := make([]int, len(a))
+	for i := range a {
+		d := f.newVar()
+		f.add(-d, a[i], b[i])
+		f.add(-d, -a[i], -b[i])
+		diff[i] = d
+	}
+	f.add(diff...)
+}
+
+// encodeCNF returns the CNF encoding of the board
+func (b Takuzu) encodeCNF() (*cnf, error) {
+	if b.Size < 2 || b.Size%2 != 0 {
+		return nil, errors.New("the board size must be even")
+	}
+	size := b.Size
+	f := &cnf{nVars: size * size}
+	cell := func(l, c int) int { return l*size + c + 1 }
+
+	for l := range b.Board {
+		for c, cl := range b.Board[l] {
+			if !cl.Defined {
+				continue
+			}
+			if cl.Value == 1 {
+				f.add(cell(l, c))
+			} else {
+				f.add(-cell(l, c))
+			}
+		}
+	}
+
+	lines := make([][]int, size)
+	columns := make([][]int, size)
+	for i := 0; i < size; i++ {
+		lines[i] = make([]int, size)
+		columns[i] = make([]int, size)
+		for j := 0; j < size; j++ {
+			lines[i][j] = cell(i, j)
+			columns[i][j] = cell(j, i)
+		}
+	}
+
+	for _, ranges := range [][][]int{lines, columns} {
+		for i, r := range ranges {
+			for j := 0; j+2 < size; j++ {
+				f.add(r[j], r[j+1], r[j+2])
+				f.add(-r[j], -r[j+1], -r[j+2])
+			}
+			neg := make([]int, size)
+			for j, x := range r {
+				neg[j] = -x
+			}
+			f.atMost(r, size/2)
+			f.atMost(neg, size/2)
+			for _, other := range ranges[:i] {
+				f.different(other, r)
+			}
+		}
+	}
+	return f, nil
+}
+
+// WriteDIMACS writes the CNF encoding of the board in DIMACS format, for
+// use with external SAT solvers.  The variable l*size+c+1 is the cell
+// (l, c) and is true for the value 1.
+func (b Takuzu) WriteDIMACS(w io.Writer) error {
+	f, err := b.encodeCNF()
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "c takuzu %d %s\n", b.Size, b.ToString())
+	fmt.Fprintf(bw, "c cell (l,c) is variable l*%d+c+1, true for 1\n", b.Size)
+	fmt.Fprintf(bw, "p cnf %d %d\n", f.nVars, len(f.clauses))
+	for _, c := range f.clauses {
+		for _, x := range c {
+			fmt.Fprintf(bw, "%d ", x)
+		}
+		fmt.Fprintln(bw, "0")
+	}
+	return bw.Flush()
+}
+
+// satLiteral converts a DIMACS literal
+func satLiteral(x int) satLit {
+	if x < 0 {
+		return satLit(2*(-x-1) + 1)
+	}
+	return satLit(2 * (x - 1))
+}
+
+// TrySolveSAT solves the takuzu with the SAT backend, independently of
+// TrySolveRecurse.  It follows the semantics of TrySolveWithOptions: if
+// allSolutions is not nil, all the solutions (up to opts.MaxSolutions) are
+// looked for, each new solution being excluded with a blocking clause.
+// The Propagate and Heuristic options are ignored.  The board is not
+// updated.
+func (b Takuzu) TrySolveSAT(allSolutions *[]Takuzu, opts SolveOptions) (*Takuzu, error) {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	startTime := time.Now()
+	stop := func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if opts.Timeout > 0 && time.Since(startTime) > opts.Timeout {
+			return errors.New("timeout")
+		}
+		return nil
+	}
+
+	f, err := b.encodeCNF()
+	if err != nil {
+		return nil, err
+	}
+	s := newSATSolver(f.nVars)
+	for v := 0; v < b.Size*b.Size; v++ {
+		s.bumpActivity(v)
+	}
+	lits := make([]satLit, 0, b.Size)
+	for _, c := range f.clauses {
+		lits = lits[:0]
+		for _, x := range c {
+			lits = append(lits, satLiteral(x))
+		}
+		if !s.addClause(lits) {
+			break
+		}
+	}
+	if verbosity > 1 {
+		log.Printf("SAT: %d variables, %d clauses", f.nVars, len(f.clauses))
+	}
+
+	var first *Takuzu
+	for {
+		sat, err := s.solve(stop)
+		if err != nil {
+			return first, err
+		}
+		if !sat {
+			break
+		}
+
+		tak := New(b.Size)
+		blocking := make([]satLit, 0, b.Size*b.Size)
+		for l := range tak.Board {
+			for c := range tak.Board[l] {
+				x := satLit(2 * (l*b.Size + c))
+				if s.value(x) == satTrue {
+					tak.Board[l][c].Set(1)
+					blocking = append(blocking, x^1)
+				} else {
+					tak.Board[l][c].Set(0)
+					blocking = append(blocking, x)
+				}
+			}
+		}
+		if first == nil {
+			first = &tak
+		}
+		if allSolutions == nil {
+			return first, nil
+		}
+		*allSolutions = append(*allSolutions, tak)
+		if verbosity > 1 {
+			log.Printf("SAT: solution #%d found", len(*allSolutions))
+		}
+		if opts.MaxSolutions > 0 && len(*allSolutions) >= opts.MaxSolutions {
+			break
+		}
+
+		// Enumerating the solutions of sparse boards can take very
+		// few conflicts, so the stop function is called for each
+		// solution as well.
+		if err := stop(); err != nil {
+			return first, err
+		}
+
+		// Exclude this solution
+		s.cancelUntil(0)
+		if !s.addClause(blocking) {
+			break
+		}
+	}
+
+	if first == nil {
+		return nil, ErrNoSolution
+	}
+	return first, nil
+}
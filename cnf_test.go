@@ -0,0 +1,171 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestSATMatchesRecurse(t *testing.T) {
+	for _, b := range testBoards(t) {
+		want := &[]Takuzu{}
+		b.Clone().TrySolveWithOptions(want, SolveOptions{})
+		wantStrings := solutionStrings(*want)
+
+		got := &[]Takuzu{}
+		first, err := b.TrySolveSAT(got, SolveOptions{})
+		gotStrings := solutionStrings(*got)
+		if !reflect.DeepEqual(gotStrings, wantStrings) {
+			t.Errorf("%s: TrySolveSAT found %d solutions, want %d",
+				b.ToString(), len(gotStrings), len(wantStrings))
+			continue
+		}
+		if len(wantStrings) == 0 && errors.Cause(err) != ErrNoSolution {
+			t.Errorf("%s: TrySolveSAT returned %v, want %v", b.ToString(), err, ErrNoSolution)
+		}
+		if len(wantStrings) > 0 && (err != nil || first == nil) {
+			t.Errorf("%s: TrySolveSAT: %v", b.ToString(), err)
+		}
+
+		// Single solution
+		sol, err := b.TrySolveSAT(nil, SolveOptions{})
+		if len(wantStrings) > 0 {
+			if err != nil || sol == nil {
+				t.Errorf("%s: TrySolveSAT: %v", b.ToString(), err)
+			} else if full, err := sol.Validate(); !full || err != nil {
+				t.Errorf("%s: TrySolveSAT returned an invalid board %s", b.ToString(), sol.ToString())
+			} else if match, _, _ := BoardsMatch(&b, sol, true); !match {
+				t.Errorf("%s: TrySolveSAT returned %s, which does not match", b.ToString(), sol.ToString())
+			}
+		}
+
+		// Limited search
+		if len(wantStrings) > 1 {
+			got = &[]Takuzu{}
+			if _, err := b.TrySolveSAT(got, SolveOptions{MaxSolutions: 2}); err != nil || len(*got) != 2 {
+				t.Errorf("%s: TrySolveSAT found %d solutions (%v), want 2",
+					b.ToString(), len(*got), err)
+			}
+		}
+	}
+}
+
+// parseDIMACS reads a formula in DIMACS format
+func parseDIMACS(t *testing.T, s string) *cnf {
+	t.Helper()
+	f := &cnf{}
+	nClauses := -1
+	var clause []int
+	sc := bufio.NewScanner(strings.NewReader(s))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || fields[0] == "c" {
+			continue
+		}
+		if fields[0] == "p" {
+			if len(fields) != 4 || fields[1] != "cnf" {
+				t.Fatalf("bad problem line %q", sc.Text())
+			}
+			f.nVars, _ = strconv.Atoi(fields[2])
+			nClauses, _ = strconv.Atoi(fields[3])
+			continue
+		}
+		for _, x := range fields {
+			n, err := strconv.Atoi(x)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n == 0 {
+				f.clauses = append(f.clauses, clause)
+				clause = nil
+				continue
+			}
+			if n > f.nVars || -n > f.nVars {
+				t.Fatalf("literal %d out of range", n)
+			}
+			clause = append(clause, n)
+		}
+	}
+	if clause != nil {
+		t.Fatal("unterminated clause")
+	}
+	if nClauses != len(f.clauses) {
+		t.Fatalf("%d clauses announced, %d found", nClauses, len(f.clauses))
+	}
+	return f
+}
+
+func TestDIMACSRoundTrip(t *testing.T) {
+	const puzzle = "..0.....0.............0.1....1.....1"
+	const solution = "010110100101011010110100101001001011"
+	b, err := NewFromString(puzzle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := b.WriteDIMACS(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "c takuzu 6 "+puzzle+"\n") {
+		t.Error("the board is missing from the comments")
+	}
+
+	f := parseDIMACS(t, buf.String())
+	want, err := b.encodeCNF()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Fatal("the parsed formula differs from the encoding")
+	}
+
+	// The parsed formula has the unique solution of the puzzle
+	s := newSATSolver(f.nVars)
+	for _, c := range f.clauses {
+		lits := make([]satLit, len(c))
+		for i, x := range c {
+			lits[i] = satLiteral(x)
+		}
+		if !s.addClause(lits) {
+			t.Fatal("unsatisfiable formula")
+		}
+	}
+	noStop := func() error { return nil }
+	if sat, err := s.solve(noStop); !sat || err != nil {
+		t.Fatalf("solve returned %v (%v)", sat, err)
+	}
+	var sol strings.Builder
+	blocking := make([]satLit, 0, b.Size*b.Size)
+	for v := 0; v < b.Size*b.Size; v++ {
+		x := satLiteral(v + 1)
+		if s.value(x) == satTrue {
+			sol.WriteByte('1')
+			blocking = append(blocking, x^1)
+		} else {
+			sol.WriteByte('0')
+			blocking = append(blocking, x)
+		}
+	}
+	if sol.String() != solution {
+		t.Errorf("got solution %s, want %s", sol.String(), solution)
+	}
+	s.cancelUntil(0)
+	if s.addClause(blocking) {
+		if sat, err := s.solve(noStop); sat || err != nil {
+			t.Errorf("second solution found (%v)", err)
+		}
+	}
+
+	if err := New(5).WriteDIMACS(&buf); err == nil {
+		t.Error("no error for an odd board size")
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+// This file contains the functions used to read and write puzzle collections.
+//
+// A collection file contains one board string per line, optionally followed
+// by a free-form comment separated by blanks (e.g. the difficulty).
+// Empty lines and lines starting with a '#' are ignored.
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CollectionEntry is a board from a puzzle collection
+type CollectionEntry struct {
+	Board   *Takuzu
+	Comment string
+}
+
+// ParseCollectionLine parses a line from a collection file.
+// It returns a nil entry if the line is empty or is a comment.
+func ParseCollectionLine(line string) (*CollectionEntry, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+
+	var comment string
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		comment = strings.TrimSpace(line[i:])
+		line = line[:i]
+	}
+
+	b, err := NewFromString(line)
+	if err != nil {
+		return nil, err
+	}
+	return &CollectionEntry{Board: b, Comment: comment}, nil
+}
+
+// ReadCollection reads all the boards from a collection
+func ReadCollection(r io.Reader) ([]CollectionEntry, error) {
+	var entries []CollectionEntry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	n := 0
+	for scanner.Scan() {
+		n++
+		e, err := ParseCollectionLine(scanner.Text())
+		if err != nil {
+			return entries, errors.Wrapf(err, "line %d", n)
+		}
+		if e != nil {
+			entries = append(entries, *e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return entries, err
+	}
+	return entries, nil
+}
+
+// WriteCollectionEntry writes a single collection entry as a line
+func WriteCollectionEntry(w io.Writer, e CollectionEntry) error {
+	if e.Board == nil {
+		return errors.New("no board")
+	}
+	var err error
+	if e.Comment != "" {
+		_, err = fmt.Fprintf(w, "%s %s\n", e.Board.ToString(), e.Comment)
+	} else {
+		_, err = fmt.Fprintln(w, e.Board.ToString())
+	}
+	return err
+}
+
+// WriteCollection writes a list of boards as a collection
+func WriteCollection(w io.Writer, entries []CollectionEntry) error {
+	for _, e := range entries {
+		if err := WriteCollectionEntry(w, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
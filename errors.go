@@ -4,9 +4,21 @@
 
 package takuzu
 
-import "fmt"
+import (
+	"fmt"
 
-// This file contains the takuzu validation error type.
+	"github.com/pkg/errors"
+)
+
+// This file contains the takuzu validation error type and the resolution
+// errors.
+
+// ErrNoSolution is returned when a board has no solution
+var ErrNoSolution = errors.New("no solution")
+
+// ErrMultipleSolutions is returned when a board has several solutions and
+// a single one is expected
+var ErrMultipleSolutions = errors.New("multiple solutions")
 
 const (
 	ErrorNil = iota
@@ -0,0 +1,450 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+// This file contains the game session model.
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Game errors
+var (
+	ErrLockedCell   = errors.New("given cells cannot be changed")
+	ErrInvalidCell  = errors.New("invalid cell position")
+	ErrInvalidValue = errors.New("invalid cell value")
+	ErrNoUndo       = errors.New("nothing to undo")
+	ErrNoRedo       = errors.New("nothing to redo")
+)
+
+// MoveKind is the kind of a move in the game log
+type MoveKind string
+
+// Move kinds
+const (
+	MovePlay MoveKind = "play"
+	MoveUndo MoveKind = "undo"
+	MoveRedo MoveKind = "redo"
+)
+
+// Move is a change of a cell value in a game.
+// The values are 0, 1, or -1 for an empty cell.
+type Move struct {
+	Kind MoveKind  `json:"kind"`
+	Line int       `json:"line"`
+	Col  int       `json:"col"`
+	Old  int       `json:"old"`
+	New  int       `json:"new"`
+	Time time.Time `json:"time"`
+}
+
+// Annotation is a set of pencil marks on a cell.
+// Annotations are not taken into account by the validation functions.
+type Annotation uint8
+
+// Annotation marks
+const (
+	MarkZero Annotation = 1 << iota // Tentative 0
+	MarkOne                         // Tentative 1
+	NotZero                         // The cell cannot be 0
+	NotOne                          // The cell cannot be 1
+)
+
+// Game is a takuzu game session.
+// The Moves log is append-only: undoing or redoing a move adds a new entry
+// to the log.
+// The current board should be changed with Play, Undo, Redo or SetState;
+// if it is modified directly, ResetState must be called.
+type Game struct {
+	Puzzle Takuzu // Initial board
+	State  Takuzu // Current board
+	Moves  []Move
+
+	undo        []int // Indexes of the moves that can be undone
+	redo        []int // Indexes of the moves that can be redone
+	annotations map[Position]Annotation
+	solution    *Takuzu     // Cached solution of the puzzle
+	state       *BoardState // Incremental state of the current board
+}
+
+// annotationJSON is the JSON representation of the annotation of a cell
+type annotationJSON struct {
+	Line  int        `json:"line"`
+	Col   int        `json:"col"`
+	Marks Annotation `json:"marks"`
+}
+
+// gameJSON is the JSON representation of a game
+type gameJSON struct {
+	Puzzle      string           `json:"puzzle"`
+	State       string           `json:"state"`
+	Moves       []Move           `json:"moves"`
+	Undo        []int            `json:"undo,omitempty"`
+	Redo        []int            `json:"redo,omitempty"`
+	Annotations []annotationJSON `json:"annotations,omitempty"`
+}
+
+// NewGame creates a new game session from a puzzle
+func NewGame(puzzle Takuzu) *Game {
+	return &Game{
+		Puzzle:      puzzle.Clone(),
+		State:       puzzle.Clone(),
+		annotations: make(map[Position]Annotation),
+	}
+}
+
+// Value returns the value of a cell of the current board, or -1 if the cell
+// is empty
+func (g *Game) Value(line, col int) int {
+	c := g.State.Board[line][col]
+	if !c.Defined {
+		return -1
+	}
+	return c.Value
+}
+
+// Given returns true if the cell is a given cell of the puzzle
+func (g *Game) Given(line, col int) bool {
+	return g.Puzzle.Board[line][col].Defined
+}
+
+// Play sets the value of a cell; the value -1 clears the cell
+func (g *Game) Play(line, col, value int) error {
+	if line < 0 || line >= g.State.Size || col < 0 || col >= g.State.Size {
+		return ErrInvalidCell
+	}
+	if value < -1 || value > 1 {
+		return ErrInvalidValue
+	}
+	if g.Given(line, col) {
+		return ErrLockedCell
+	}
+	old := g.Value(line, col)
+	if old == value {
+		return nil
+	}
+
+	g.set(line, col, value)
+	g.undo = append(g.undo, len(g.Moves))
+	g.redo = nil
+	g.Moves = append(g.Moves, Move{
+		Kind: MovePlay, Line: line, Col: col, Old: old, New: value,
+		Time: time.Now(),
+	})
+	return nil
+}
+
+// CanUndo returns true if there is a move to undo
+func (g *Game) CanUndo() bool {
+	return len(g.undo) > 0
+}
+
+// CanRedo returns true if there is a move to redo
+func (g *Game) CanRedo() bool {
+	return len(g.redo) > 0
+}
+
+// Undo cancels the last move and returns the move that was undone
+func (g *Game) Undo() (Move, error) {
+	if !g.CanUndo() {
+		return Move{}, ErrNoUndo
+	}
+	i := g.undo[len(g.undo)-1]
+	g.undo = g.undo[:len(g.undo)-1]
+	m := g.Moves[i]
+
+	g.set(m.Line, m.Col, m.Old)
+	g.redo = append(g.redo, i)
+	g.Moves = append(g.Moves, Move{
+		Kind: MoveUndo, Line: m.Line, Col: m.Col, Old: m.New, New: m.Old,
+		Time: time.Now(),
+	})
+	return m, nil
+}
+
+// Redo replays the last undone move and returns it
+func (g *Game) Redo() (Move, error) {
+	if !g.CanRedo() {
+		return Move{}, ErrNoRedo
+	}
+	i := g.redo[len(g.redo)-1]
+	g.redo = g.redo[:len(g.redo)-1]
+	m := g.Moves[i]
+
+	g.set(m.Line, m.Col, m.New)
+	g.undo = append(g.undo, i)
+	g.Moves = append(g.Moves, Move{
+		Kind: MoveRedo, Line: m.Line, Col: m.Col, Old: m.Old, New: m.New,
+		Time: time.Now(),
+	})
+	return m, nil
+}
+
+// SetState replaces the current board with a copy of state
+func (g *Game) SetState(state Takuzu) {
+	g.State = state.Clone()
+	g.ResetState()
+}
+
+// ResetState must be called after the current board has been modified
+// directly, without using the methods of the game
+func (g *Game) ResetState() {
+	g.state = nil
+}
+
+// boardState returns the incremental state of the current board, or nil if
+// the board is too large.  The state is rebuilt when it has been reset or
+// when the game has been copied.
+func (g *Game) boardState() *BoardState {
+	if g.state == nil || g.state.Board() != &g.State {
+		g.state, _ = NewBoardState(&g.State)
+	}
+	return g.state
+}
+
+// set sets the value of a cell of the current board
+func (g *Game) set(line, col, value int) {
+	if s := g.boardState(); s != nil {
+		s.Set(line, col, value)
+		return
+	}
+	g.State.Set(line, col, value)
+}
+
+// Completed returns true if the board is complete and follows the rules
+func (g *Game) Completed() bool {
+	if s := g.boardState(); s != nil {
+		return s.Full() && s.Valid()
+	}
+	full, err := g.State.Validate()
+	return full && err == nil
+}
+
+// Annotation returns the pencil marks of a cell
+func (g *Game) Annotation(line, col int) Annotation {
+	return g.annotations[Position{line, col}]
+}
+
+// Annotate sets the pencil marks of a cell; 0 clears the annotation
+func (g *Game) Annotate(line, col int, a Annotation) error {
+	if line < 0 || line >= g.State.Size || col < 0 || col >= g.State.Size {
+		return ErrInvalidCell
+	}
+	if g.Given(line, col) {
+		return ErrLockedCell
+	}
+	if g.annotations == nil {
+		g.annotations = make(map[Position]Annotation)
+	}
+	if a == 0 {
+		delete(g.annotations, Position{line, col})
+		return nil
+	}
+	g.annotations[Position{line, col}] = a
+	return nil
+}
+
+// ToggleAnnotation toggles some pencil marks of a cell
+func (g *Game) ToggleAnnotation(line, col int, a Annotation) error {
+	return g.Annotate(line, col, g.Annotation(line, col)^a)
+}
+
+// ClearAnnotations removes all the pencil marks
+func (g *Game) ClearAnnotations() {
+	g.annotations = make(map[Position]Annotation)
+}
+
+// AutoCandidates annotates the empty cells whose value can be deduced by
+// the trivial solver from the current board: the other value is marked as
+// impossible.  It returns the number of annotated cells.
+func (g *Game) AutoCandidates() (int, error) {
+	deduced := g.State.Clone()
+	if _, err := deduced.TrySolveTrivial(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for l := range deduced.Board {
+		for c, cell := range deduced.Board[l] {
+			if g.State.Board[l][c].Defined || !cell.Defined {
+				continue
+			}
+			a := g.Annotation(l, c) &^ (NotZero | NotOne)
+			if cell.Value == 0 {
+				a |= NotOne
+			} else {
+				a |= NotZero
+			}
+			g.Annotate(l, c, a)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Solution returns the solution of the puzzle.
+// An error is returned if the puzzle doesn't have exactly one solution.
+func (g *Game) Solution(timeout time.Duration) (*Takuzu, error) {
+	return g.SolutionWithOptions(SolveOptions{Timeout: timeout})
+}
+
+// SolutionWithOptions returns the solution of the puzzle, using the given
+// solver options.  The MaxSolutions option is ignored.
+func (g *Game) SolutionWithOptions(opts SolveOptions) (*Takuzu, error) {
+	if g.solution != nil {
+		return g.solution, nil
+	}
+	// Two solutions are enough to know the solution is not unique
+	opts.MaxSolutions = 2
+	allSol := &[]Takuzu{}
+	_, err := g.Puzzle.Clone().TrySolveWithOptions(allSol, opts)
+	if err != nil && isAbort(err) {
+		return nil, err
+	}
+	switch n := len(*allSol); {
+	case n == 0:
+		return nil, ErrNoSolution
+	case n > 1:
+		return nil, ErrMultipleSolutions
+	}
+	g.solution = &(*allSol)[0]
+	return g.solution, nil
+}
+
+// FirstMistake returns the position of the first filled cell (in row-major
+// order) that does not match the solution of the puzzle, or nil if there is
+// no mistake.
+func (g *Game) FirstMistake(timeout time.Duration) (*Position, error) {
+	return g.FirstMistakeWithOptions(SolveOptions{Timeout: timeout})
+}
+
+// FirstMistakeWithOptions works like FirstMistake, using the given solver
+// options.
+func (g *Game) FirstMistakeWithOptions(opts SolveOptions) (*Position, error) {
+	sol, err := g.SolutionWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if match, l, c := BoardsMatch(&g.State, sol, true); !match {
+		return &Position{Line: l, Col: c}, nil
+	}
+	return nil, nil
+}
+
+// Hint returns a hint for the player.  If a cell does not match the
+// solution, the hint points to the first wrong cell; otherwise it is a hint
+// about the easiest deduction available.  It returns nil if no hint can be
+// found.
+func (g *Game) Hint(timeout time.Duration) (*Hint, error) {
+	return g.HintWithOptions(SolveOptions{Timeout: timeout})
+}
+
+// HintWithOptions works like Hint, using the given solver options.
+func (g *Game) HintWithOptions(opts SolveOptions) (*Hint, error) {
+	p, err := g.FirstMistakeWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if p != nil {
+		return &Hint{
+			Technique: TechniqueMistake,
+			Region:    Region{Index: p.Line},
+			Line:      p.Line,
+			Col:       p.Col,
+			Value:     g.solution.Board[p.Line][p.Col].Value,
+		}, nil
+	}
+	return g.State.Hint(), nil
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (g Game) MarshalJSON() ([]byte, error) {
+	gj := gameJSON{
+		Puzzle: g.Puzzle.ToString(),
+		State:  g.State.ToString(),
+		Moves:  g.Moves,
+		Undo:   g.undo,
+		Redo:   g.redo,
+	}
+	for l := 0; l < g.State.Size; l++ {
+		for c := 0; c < g.State.Size; c++ {
+			if a := g.Annotation(l, c); a != 0 {
+				gj.Annotations = append(gj.Annotations, annotationJSON{l, c, a})
+			}
+		}
+	}
+	return json.Marshal(gj)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (g *Game) UnmarshalJSON(data []byte) error {
+	var gj gameJSON
+	if err := json.Unmarshal(data, &gj); err != nil {
+		return err
+	}
+
+	puzzle, err := NewFromString(gj.Puzzle)
+	if err != nil {
+		return errors.Wrap(err, "invalid puzzle")
+	}
+	state, err := NewFromString(gj.State)
+	if err != nil {
+		return errors.Wrap(err, "invalid state")
+	}
+	if state.Size != puzzle.Size {
+		return errors.New("puzzle and state sizes do not match")
+	}
+	for l := range puzzle.Board {
+		for c, cell := range puzzle.Board[l] {
+			if cell.Defined && state.Board[l][c] != cell {
+				return errors.New("state does not match puzzle")
+			}
+		}
+	}
+	for _, m := range gj.Moves {
+		switch m.Kind {
+		case MovePlay, MoveUndo, MoveRedo:
+		default:
+			return errors.New("invalid move kind")
+		}
+		if m.Line < 0 || m.Line >= puzzle.Size || m.Col < 0 || m.Col >= puzzle.Size {
+			return errors.New("invalid move position")
+		}
+		if puzzle.Board[m.Line][m.Col].Defined {
+			return errors.New("invalid move on a given cell")
+		}
+		if m.Old < -1 || m.Old > 1 || m.New < -1 || m.New > 1 {
+			return errors.New("invalid move value")
+		}
+	}
+	for _, stack := range [][]int{gj.Undo, gj.Redo} {
+		for _, i := range stack {
+			if i < 0 || i >= len(gj.Moves) {
+				return errors.New("invalid move index")
+			}
+		}
+	}
+
+	annotations := make(map[Position]Annotation)
+	for _, a := range gj.Annotations {
+		if a.Line < 0 || a.Line >= puzzle.Size || a.Col < 0 || a.Col >= puzzle.Size {
+			return errors.New("invalid annotation position")
+		}
+		if puzzle.Board[a.Line][a.Col].Defined {
+			return errors.New("invalid annotation on a given cell")
+		}
+		annotations[Position{a.Line, a.Col}] = a.Marks
+	}
+
+	g.Puzzle, g.State = *puzzle, *state
+	g.ResetState()
+	g.Moves, g.undo, g.redo = gj.Moves, gj.Undo, gj.Redo
+	g.annotations = annotations
+	g.solution = nil
+	return nil
+}
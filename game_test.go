@@ -0,0 +1,293 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+import (
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	// A 6x6 puzzle with a unique solution
+	testPuzzle   = "..0.....0.............0.1....1.....1"
+	testSolution = "010110100101011010110100101001001011"
+)
+
+func newTestGame(t *testing.T) *Game {
+	t.Helper()
+	puzzle, err := NewFromString(testPuzzle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewGame(*puzzle)
+}
+
+func TestGamePlay(t *testing.T) {
+	g := newTestGame(t)
+	tests := []struct {
+		line, col, value int
+		err              error
+	}{
+		{0, 2, 1, ErrLockedCell}, // Given cell
+		{0, 2, -1, ErrLockedCell},
+		{-1, 0, 1, ErrInvalidCell},
+		{0, 6, 1, ErrInvalidCell},
+		{0, 0, 2, ErrInvalidValue},
+		{0, 0, 1, nil},
+		{0, 0, -1, nil},
+	}
+	for _, tt := range tests {
+		if err := g.Play(tt.line, tt.col, tt.value); err != tt.err {
+			t.Errorf("Play(%d, %d, %d) returned %v, want %v", tt.line, tt.col, tt.value, err, tt.err)
+		}
+	}
+	if g.State.ToString() != testPuzzle {
+		t.Errorf("unexpected state %s", g.State.ToString())
+	}
+	if len(g.Moves) != 2 {
+		t.Errorf("%d moves, want 2", len(g.Moves))
+	}
+
+	// Playing the same value is not a move
+	g.Play(0, 0, -1)
+	if len(g.Moves) != 2 {
+		t.Errorf("%d moves, want 2", len(g.Moves))
+	}
+}
+
+func TestGameUndoRedo(t *testing.T) {
+	g := newTestGame(t)
+	if _, err := g.Undo(); err != ErrNoUndo {
+		t.Errorf("Undo returned %v, want %v", err, ErrNoUndo)
+	}
+	if _, err := g.Redo(); err != ErrNoRedo {
+		t.Errorf("Redo returned %v, want %v", err, ErrNoRedo)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	states := []string{g.State.ToString()}
+	for len(states) <= 20 {
+		l, c := rng.Intn(6), rng.Intn(6)
+		v := rng.Intn(3) - 1
+		if g.Given(l, c) || g.Value(l, c) == v {
+			continue
+		}
+		if err := g.Play(l, c, v); err != nil {
+			t.Fatal(err)
+		}
+		states = append(states, g.State.ToString())
+
+		// Undo followed by redo is the identity
+		g.Undo()
+		if g.State.ToString() != states[len(states)-2] {
+			t.Fatalf("undo: got %s, want %s", g.State.ToString(), states[len(states)-2])
+		}
+		g.Redo()
+		if g.State.ToString() != states[len(states)-1] {
+			t.Fatalf("redo: got %s, want %s", g.State.ToString(), states[len(states)-1])
+		}
+	}
+
+	for i := len(states) - 2; i >= 0; i-- {
+		if _, err := g.Undo(); err != nil {
+			t.Fatal(err)
+		}
+		if g.State.ToString() != states[i] {
+			t.Fatalf("undo %d: got %s, want %s", i, g.State.ToString(), states[i])
+		}
+	}
+	if g.CanUndo() {
+		t.Error("CanUndo returned true at the start")
+	}
+	for i := 1; i < len(states); i++ {
+		if _, err := g.Redo(); err != nil {
+			t.Fatal(err)
+		}
+		if g.State.ToString() != states[i] {
+			t.Fatalf("redo %d: got %s, want %s", i, g.State.ToString(), states[i])
+		}
+	}
+	if g.CanRedo() {
+		t.Error("CanRedo returned true at the end")
+	}
+
+	// The log is append-only: 20 moves undone and redone, then 20 undo
+	// and 20 redo moves
+	if len(g.Moves) != 100 {
+		t.Errorf("%d moves, want 100", len(g.Moves))
+	}
+
+	// A new move clears the redo stack
+	g.Undo()
+	g.Play(0, 0, 0)
+	if g.CanRedo() {
+		t.Error("CanRedo returned true after a move")
+	}
+}
+
+func TestGameCompleted(t *testing.T) {
+	g := newTestGame(t)
+	for i, ch := range testSolution[1:] {
+		if l, c := (i+1)/6, (i+1)%6; !g.Given(l, c) {
+			g.Play(l, c, int(ch-'0'))
+		}
+	}
+	if g.Completed() {
+		t.Fatal("completed with an empty cell")
+	}
+	g.Play(0, 0, 0)
+	if !g.Completed() {
+		t.Error("the solution is not completed")
+	}
+
+	// Copies and direct modifications
+	g2 := *g
+	g2.State = g.State.Clone()
+	g2.State.Set(0, 0, 1)
+	if g2.Completed() || !g.Completed() {
+		t.Error("the copy shares the state of the game")
+	}
+	g.State.Set(0, 0, 1)
+	g.ResetState()
+	if g.Completed() {
+		t.Error("stale state after ResetState")
+	}
+	sol, _ := NewFromString(testSolution)
+	g.SetState(*sol)
+	if !g.Completed() {
+		t.Error("stale state after SetState")
+	}
+}
+
+func TestGameJSON(t *testing.T) {
+	g := newTestGame(t)
+	g.Play(0, 0, 0)
+	g.Play(0, 1, 1)
+	g.Play(1, 0, 1)
+	g.Undo()
+
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var g2 Game
+	if err := json.Unmarshal(data, &g2); err != nil {
+		t.Fatal(err)
+	}
+	if g2.Puzzle.ToString() != g.Puzzle.ToString() || g2.State.ToString() != g.State.ToString() {
+		t.Error("the boards differ after a round trip")
+	}
+	if len(g2.Moves) != len(g.Moves) || !reflect.DeepEqual(g2.undo, g.undo) || !reflect.DeepEqual(g2.redo, g.redo) {
+		t.Error("the move log differs after a round trip")
+	}
+	g2.Redo()
+	g.Redo()
+	if g2.State.ToString() != g.State.ToString() {
+		t.Error("redo differs after a round trip")
+	}
+
+	// A game can be reused for another puzzle
+	if _, err := g2.Solution(0); err != nil {
+		t.Fatal(err)
+	}
+	other, _ := NewFromString("1.0.............")
+	data, _ = json.Marshal(NewGame(*other))
+	if err := json.Unmarshal(data, &g2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g2.Solution(0); err != ErrMultipleSolutions {
+		t.Errorf("Solution returned %v, want %v", err, ErrMultipleSolutions)
+	}
+}
+
+func TestGameJSONErrors(t *testing.T) {
+	g := newTestGame(t)
+	g.Play(0, 0, 0)
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := string(data)
+
+	for _, r := range []struct{ old, new string }{
+		{`"kind":"play"`, `"kind":"jump"`},
+		{`"line":0`, `"line":6`},
+		{`"col":0`, `"col":-1`},
+		{`"col":0`, `"col":2`}, // Given cell
+		{`"new":0`, `"new":2`},
+		{`"old":-1`, `"old":-2`},
+		{`"undo":[0]`, `"undo":[1]`},
+		{`"state":"0.0`, `"state":"0.1`}, // Given cell changed
+		{`"state":"0.0`, `"state":"0.0.`},
+		{`"puzzle":"..0`, `"puzzle":"..x`},
+	} {
+		bad := strings.Replace(valid, r.old, r.new, 1)
+		if bad == valid {
+			t.Fatalf("%s not found in %s", r.old, valid)
+		}
+		var g2 Game
+		if err := json.Unmarshal([]byte(bad), &g2); err == nil {
+			t.Errorf("no error for %s", bad)
+		}
+	}
+}
+
+func TestGameAnnotations(t *testing.T) {
+	g := newTestGame(t)
+	if err := g.Annotate(0, 2, MarkOne); err != ErrLockedCell {
+		t.Errorf("Annotate returned %v, want %v", err, ErrLockedCell)
+	}
+	if err := g.Annotate(6, 0, MarkOne); err != ErrInvalidCell {
+		t.Errorf("Annotate returned %v, want %v", err, ErrInvalidCell)
+	}
+	g.Annotate(0, 0, MarkOne)
+	g.ToggleAnnotation(0, 0, MarkOne|MarkZero)
+	if a := g.Annotation(0, 0); a != MarkZero {
+		t.Errorf("annotation %d, want %d", a, MarkZero)
+	}
+	g.ClearAnnotations()
+	if a := g.Annotation(0, 0); a != 0 {
+		t.Errorf("annotation %d after ClearAnnotations", a)
+	}
+
+	// The candidates agree with the solution
+	n, err := g.AutoCandidates()
+	if err != nil || n == 0 {
+		t.Fatalf("AutoCandidates returned %d (%v)", n, err)
+	}
+	for i, ch := range testSolution {
+		l, c := i/6, i%6
+		a := g.Annotation(l, c)
+		if g.Given(l, c) && a != 0 || ch == '0' && a&NotZero != 0 || ch == '1' && a&NotOne != 0 {
+			t.Errorf("cell [%d,%d]: wrong annotation %d", l, c, a)
+		}
+	}
+
+	// Annotations are saved
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var g2 Game
+	if err := json.Unmarshal(data, &g2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g2.annotations, g.annotations) {
+		t.Error("the annotations differ after a round trip")
+	}
+
+	for _, a := range []string{
+		`{"line":0,"col":2,"marks":1}`, // Given cell
+		`{"line":0,"col":6,"marks":1}`,
+	} {
+		bad := strings.Replace(string(data), `"annotations":[`, `"annotations":[`+a+`,`, 1)
+		if err := json.Unmarshal([]byte(bad), &g2); err == nil {
+			t.Errorf("no error for the annotation %s", a)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/McKael/takuzu"
+)
+
+var batchCommand = &command{
+	name:        "batch",
+	args:        "[FILE]",
+	description: "Solve or validate many boards (one per line) from a collection file",
+	exitCodes: []string{
+		"0  All the boards were processed successfully",
+		"1  Some boards are invalid, unsolvable or ambiguous, or could not be read",
+		"4  Some boards could not be processed before the timeout",
+	},
+	run: runBatch,
+}
+
+// batchItem is a board from the batch input, with its processing result
+type batchItem struct {
+	index int
+	line  int // Line number in the input
+	input string
+	rep   *report
+}
+
+func runBatch(fs *pflag.FlagSet, args []string) int {
+	cf := addCommonFlags(fs)
+	mode := fs.String("mode", "solve", "Processing mode (solve, validate, crosscheck)")
+	jobs := fs.Uint("jobs", uint(runtime.NumCPU()), "Number of parallel workers")
+	resolveTimeout := fs.Duration("x-timeout", 0, "[Advanced] Resolution timeout per board")
+	sat := fs.Bool("x-sat", false, "[Advanced] Use the SAT solver backend")
+	heuristic := fs.String("x-heuristic", "default", "[Advanced] Cell selection heuristic (default, first, constrained, neighbours, probe)")
+
+	if code := parseFlags(fs, cf, args); code >= 0 {
+		return code
+	}
+
+	h, err := takuzu.ParseHeuristic(*heuristic)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitUsage
+	}
+
+	opts := takuzu.SolveOptions{Timeout: *resolveTimeout, Heuristic: h}
+	var process func(tak *takuzu.Takuzu, rep *report)
+	switch *mode {
+	case "solve":
+		process = func(tak *takuzu.Takuzu, rep *report) {
+			batchSolve(tak, rep, opts, *sat)
+		}
+	case "crosscheck":
+		process = func(tak *takuzu.Takuzu, rep *report) {
+			batchCrossCheck(tak, rep, opts)
+		}
+	case "validate":
+		process = batchValidate
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown batch mode '%s'\n", *mode)
+		return exitUsage
+	}
+	if *jobs == 0 {
+		*jobs = 1
+	}
+
+	in := io.Reader(os.Stdin)
+	if fs.NArg() > 0 && fs.Arg(0) != "-" {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			log.Println(err)
+			return exitError
+		}
+		defer f.Close()
+		in = f
+	}
+
+	items := make(chan *batchItem)
+	results := make(chan *batchItem)
+
+	// Reader
+	var readErr error
+	go func() {
+		defer close(items)
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(nil, 1024*1024)
+		n, index := 0, 0
+		for scanner.Scan() {
+			n++
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			items <- &batchItem{index: index, line: n, input: line}
+			index++
+		}
+		readErr = scanner.Err()
+	}()
+
+	// Workers
+	var wg sync.WaitGroup
+	for i := 0; i < int(*jobs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				rep := newReport(*mode)
+				rep.Line = item.line
+				e, err := takuzu.ParseCollectionLine(item.input)
+				if err != nil {
+					rep.Board = item.input
+					rep.finish("error", err)
+				} else {
+					rep.setBoard(e.Board)
+					process(e.Board, rep)
+				}
+				item.rep = rep
+				results <- item
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Write the results in input order
+	summary := make(map[string]int)
+	pending := make(map[int]*batchItem)
+	next := 0
+	start := time.Now()
+	w := bufio.NewWriter(os.Stdout)
+	for item := range results {
+		pending[item.index] = item
+		for {
+			it, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			summary[it.rep.Status]++
+			writeBatchResult(w, it.rep)
+		}
+		w.Flush()
+	}
+
+	if readErr != nil {
+		log.Println(readErr)
+	}
+	writeBatchSummary(w, summary, next, time.Since(start))
+	w.Flush()
+
+	switch {
+	case readErr != nil:
+		return exitError
+	case summary["timeout"] > 0:
+		return exitTimeout
+	case next > summary["solved"]+summary["complete"]+summary["incomplete"]:
+		return exitError
+	}
+	return exitOK
+}
+
+// batchSolve solves a board, checking that the solution is unique.
+// The solutions are only reported if the solution is unique.
+// If sat is true, the SAT solver backend is used.
+func batchSolve(tak *takuzu.Takuzu, rep *report, opts takuzu.SolveOptions, sat bool) {
+	allSol := &[]takuzu.Takuzu{}
+	var err error
+	if sat {
+		_, err = tak.TrySolveSAT(allSol, opts)
+	} else {
+		_, err = tak.Clone().TrySolveWithOptions(allSol, opts)
+	}
+	if len(*allSol) == 1 {
+		rep.setSolutions(*allSol)
+	} else {
+		n := len(*allSol)
+		rep.SolutionCount = &n
+	}
+	switch {
+	case isTimeout(err):
+		rep.finish("timeout", err)
+	case len(*allSol) == 1:
+		rep.finish("solved", nil)
+	case len(*allSol) > 1:
+		rep.finish("multiple", nil)
+	default:
+		if _, verr := tak.Validate(); verr != nil {
+			rep.finish("invalid", verr)
+			return
+		}
+		rep.finish("unsolvable", err)
+	}
+}
+
+// batchCrossCheck solves a board with the recursive solver and checks the
+// result with the SAT solver backend
+func batchCrossCheck(tak *takuzu.Takuzu, rep *report, opts takuzu.SolveOptions) {
+	batchSolve(tak, rep, opts, false)
+	switch rep.Status {
+	case "solved", "multiple", "unsolvable":
+	default:
+		return
+	}
+
+	n := 0
+	if rep.SolutionCount != nil {
+		n = *rep.SolutionCount
+	}
+	// One more solution is looked for, to detect missing solutions
+	opts.MaxSolutions = n + 1
+	allSol := &[]takuzu.Takuzu{}
+	_, err := tak.TrySolveSAT(allSol, opts)
+	if isTimeout(err) {
+		rep.finish("timeout", err)
+		return
+	}
+	if len(*allSol) != n || (n == 1 && (*allSol)[0].ToString() != rep.Solutions[0]) {
+		rep.Error = ""
+		rep.finish("mismatch", errors.Errorf("the SAT backend found %d solution(s)", len(*allSol)))
+	}
+}
+
+// batchValidate checks a board against the rules
+func batchValidate(tak *takuzu.Takuzu, rep *report) {
+	full, err := tak.Validate()
+	switch {
+	case err != nil:
+		rep.finish("invalid", err)
+	case !full:
+		rep.finish("incomplete", nil)
+	default:
+		rep.finish("complete", nil)
+	}
+}
+
+// writeBatchResult writes a result line
+func writeBatchResult(w io.Writer, rep *report) {
+	if jsonOutput() {
+		if err := json.NewEncoder(w).Encode(rep); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+	fields := []string{rep.Board, rep.Status}
+	if len(rep.Solutions) == 1 {
+		fields = append(fields, rep.Solutions[0])
+	} else if rep.SolutionCount != nil && *rep.SolutionCount > 1 {
+		fields = append(fields, fmt.Sprintf("(%d solutions)", *rep.SolutionCount))
+	}
+	if rep.Error != "" {
+		fields = append(fields, "("+rep.Error+")")
+	}
+	fmt.Fprintln(w, strings.Join(fields, " "))
+}
+
+// writeBatchSummary writes the result counts
+func writeBatchSummary(w io.Writer, summary map[string]int, total int, elapsed time.Duration) {
+	if jsonOutput() {
+		doc := struct {
+			Summary map[string]int `json:"summary"`
+			Total   int            `json:"total"`
+			Elapsed float64        `json:"elapsed"`
+		}{summary, total, elapsed.Seconds()}
+		if err := json.NewEncoder(w).Encode(doc); err != nil {
+			log.Println(errors.Wrap(err, "summary"))
+		}
+		return
+	}
+	var status []string
+	for s := range summary {
+		status = append(status, s)
+	}
+	sort.Strings(status)
+	fields := []string{fmt.Sprintf("total=%d", total)}
+	for _, s := range status {
+		fields = append(fields, fmt.Sprintf("%s=%d", s, summary[s]))
+	}
+	fmt.Fprintf(w, "# Summary: %s (%v)\n", strings.Join(fields, " "), elapsed.Round(time.Millisecond))
+}
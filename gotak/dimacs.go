@@ -0,0 +1,32 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/McKael/takuzu"
+)
+
+func tak2dimacs(tak *takuzu.Takuzu, dimacsFileName string) error {
+
+	if dimacsFileName == "" {
+		return errors.New("no DIMACS file name")
+	}
+
+	f, err := os.Create(dimacsFileName)
+	if err != nil {
+		return err
+	}
+
+	if err := tak.WriteDIMACS(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,204 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/McKael/takuzu"
+)
+
+var generateCommand = &command{
+	name:        "generate",
+	args:        "",
+	description: "Build many unique puzzles and write them to a collection file",
+	exitCodes: []string{
+		"0  All the puzzles were generated",
+		"1  The puzzles could not be generated or written",
+	},
+	run: runGenerate,
+}
+
+// generatedPuzzle is a puzzle built by a generation worker
+type generatedPuzzle struct {
+	worker     int
+	board      *takuzu.Takuzu
+	difficulty takuzu.Difficulty
+	err        error
+	fatal      bool // The puzzles cannot be built with these options
+}
+
+func runGenerate(fs *pflag.FlagSet, args []string) int {
+	cf := addCommonFlags(fs)
+	bf := addBuildFlags(fs)
+	size := fs.Uint("size", 0, "Board size")
+	count := fs.Uint("count", 1, "Number of puzzles")
+	difficulty := fs.String("difficulty", "", "Required difficulty (easy, hard)")
+	output := fs.String("output", "", "Collection output file (default: standard output)")
+	appendMode := fs.Bool("append", false, "Append to the output file, skipping the puzzles already present")
+	workers := fs.Uint("workers", uint(runtime.NumCPU()), "Number of parallel workers")
+	gradeTimeout := fs.Duration("x-grade-timeout", 5*time.Minute, "[Advanced] Grading timeout")
+	maxAttempts := fs.Uint("x-max-attempts", 0, "[Advanced] Maximum number of puzzles built (default: 100 per requested puzzle)")
+
+	if code := parseFlags(fs, cf, args); code >= 0 {
+		return code
+	}
+
+	rep := newReport("generate")
+
+	if *size == 0 {
+		return rep.done("error", errors.New("no board size"), exitUsage)
+	}
+	if *size%2 != 0 || *size < 4 {
+		return rep.done("error", errors.New("board size should be an even value of at least 4"), exitUsage)
+	}
+	if *maxAttempts == 0 {
+		*maxAttempts = 100 * *count
+	}
+	if *workers == 0 {
+		*workers = 1
+	}
+
+	var wantedDifficulty takuzu.Difficulty
+	if *difficulty != "" {
+		d, err := takuzu.ParseDifficulty(*difficulty)
+		if err != nil {
+			return rep.done("error", err, exitUsage)
+		}
+		wantedDifficulty = d
+	}
+
+	// Set of the canonical strings of the known puzzles
+	seen := make(map[string]bool)
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if *appendMode {
+			entries, err := readCollectionFile(*output)
+			if err != nil && !os.IsNotExist(errors.Cause(err)) {
+				return rep.done("error", err, exitError)
+			}
+			for _, e := range entries {
+				seen[e.Board.CanonicalString()] = true
+			}
+			flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		f, err := os.OpenFile(*output, flags, 0644)
+		if err != nil {
+			return rep.done("error", err, exitError)
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+
+	bf.logSettings()
+
+	// The workers build puzzles continuously until the context is
+	// canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := make(chan generatedPuzzle)
+	for i := 0; i < int(*workers); i++ {
+		go func(i int) {
+			for {
+				// Build trivial puzzles directly when they are requested
+				simple := wantedDifficulty == takuzu.DifficultyEasy
+				tak, err := takuzu.NewRandomTakuzuWithOptions(bf.options(ctx, int(*size), simple, fmt.Sprint(i)))
+				// NewRandomTakuzuWithOptions retries on its own, so
+				// its errors are not transient.
+				res := generatedPuzzle{worker: i, board: tak, err: err, fatal: err != nil}
+				if err == nil && tak != nil {
+					res.difficulty, res.err = tak.GradeWithOptions(takuzu.SolveOptions{
+						Timeout: *gradeTimeout,
+						Context: ctx,
+					})
+				}
+				select {
+				case results <- res:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(i)
+	}
+
+	var generated, duplicates, rejected, attempts int
+	for generated < int(*count) {
+		if attempts >= int(*maxAttempts) {
+			return rep.done("error", errors.Errorf("only %d puzzle(s) generated after %d attempts", generated, attempts), exitError)
+		}
+		res := <-results
+		attempts++
+		switch {
+		case res.fatal:
+			return rep.done("error", res.err, exitError)
+		case res.err != nil || res.board == nil:
+			rejected++
+			if verbosity > 0 {
+				log.Printf("Worker #%d: puzzle rejected (%v)", res.worker, res.err)
+			}
+			continue
+		case wantedDifficulty != takuzu.DifficultyUnknown && res.difficulty != wantedDifficulty:
+			rejected++
+			continue
+		}
+
+		key := res.board.CanonicalString()
+		if seen[key] {
+			duplicates++
+			continue
+		}
+		seen[key] = true
+
+		err := takuzu.WriteCollectionEntry(w, takuzu.CollectionEntry{
+			Board:   res.board,
+			Comment: res.difficulty.String(),
+		})
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			return rep.done("error", err, exitError)
+		}
+		generated++
+
+		log.Printf("Generated %d/%d puzzles (%d duplicate(s), %d rejected) - %v",
+			generated, *count, duplicates, rejected,
+			time.Since(rep.start).Round(time.Second))
+	}
+	cancel()
+
+	rep.Count = generated
+	if *output != "" {
+		rep.Files = []string{*output}
+	}
+	if jsonOutput() && *output == "" {
+		// The collection has been written to the standard output
+		return exitOK
+	}
+	return rep.done("generated", nil, exitOK)
+}
+
+// readCollectionFile reads the boards of a collection file
+func readCollectionFile(fileName string) ([]takuzu.CollectionEntry, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return takuzu.ReadCollection(f)
+}
@@ -7,11 +7,12 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
-	"log"
 	"os"
-	"time"
+	"strings"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
 
 	"github.com/McKael/takuzu"
@@ -19,198 +20,176 @@ import (
 
 var verbosity int
 
-func newTakuzuGameBoard(size int, simple bool, jobs int, buildBoardTimeout, reduceBoardTimeout time.Duration, minRatio, maxRatio int) *takuzu.Takuzu {
-	results := make(chan *takuzu.Takuzu)
+// Exit codes
+const (
+	exitOK         = 0   // Success
+	exitError      = 1   // Invalid board or processing error
+	exitNoSolution = 2   // No solution, incomplete board or no hint
+	exitMultiple   = 3   // Several solutions
+	exitTimeout    = 4   // Timeout
+	exitUsage      = 255 // Usage error
+)
+
+// command is a gotak subcommand
+type command struct {
+	name        string
+	args        string // Usage synopsis of the arguments
+	description string
+	exitCodes   []string // Documentation of the exit codes
+	run         func(fs *pflag.FlagSet, args []string) int
+}
 
-	newTak := func(i int) {
-		takuzu, err := takuzu.NewRandomTakuzu(size, simple, fmt.Sprintf("%v", i),
-			buildBoardTimeout, reduceBoardTimeout, minRatio, maxRatio)
+var commands = []*command{
+	solveCommand,
+	newCommand,
+	generateCommand,
+	reduceCommand,
+	validateCommand,
+	hintCommand,
+	renderCommand,
+	gradeCommand,
+	batchCommand,
+	playCommand,
+	serveCommand,
+}
 
-		if err == nil && takuzu != nil {
-			results <- takuzu
-			if verbosity > 0 && jobs > 1 {
-				log.Printf("Worker #%d done.", i)
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s COMMAND [OPTIONS]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nUse '%s COMMAND --help' for the options of a command.\n", os.Args[0])
+}
+
+// newFlagSet returns a flag set for the command, with a usage function
+// documenting its flags and exit codes.
+func (c *command) newFlagSet() *pflag.FlagSet {
+	fs := pflag.NewFlagSet(c.name, pflag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s [OPTIONS] %s\n\n%s\n\nOptions:\n",
+			os.Args[0], c.name, c.args, c.description)
+		fmt.Fprint(os.Stderr, fs.FlagUsages())
+		if len(c.exitCodes) > 0 {
+			fmt.Fprintln(os.Stderr, "\nExit codes:")
+			for _, e := range c.exitCodes {
+				fmt.Fprintln(os.Stderr, "  "+e)
 			}
-		} else {
-			results <- nil
 		}
 	}
+	return fs
+}
 
-	if jobs == 0 {
-		return nil
-	}
-	for i := 0; i < jobs; i++ {
-		go newTak(i)
+// commonFlags are the flags shared by all commands
+type commonFlags struct {
+	verbosity *uint
+	format    *string
+	schrodLvl *uint
+}
+
+func addCommonFlags(fs *pflag.FlagSet) *commonFlags {
+	return &commonFlags{
+		verbosity: fs.Uint("vl", 0, "Verbosity Level"),
+		format:    fs.String("format", "text", "Output format (text, html, markdown, json)"),
+		schrodLvl: fs.Uint("x-sl", 0, "[Advanced] Schrödinger level"),
 	}
-	tak := <-results
-	return tak
 }
 
-func main() {
-	vbl := pflag.Uint("vl", 0, "Verbosity Level")
-	simple := pflag.Bool("simple", false, "Only look for trivial solutions")
-	out := pflag.Bool("out", false, "Send solution string to output")
-	board := pflag.String("board", "", "Load board string")
-	schrodLvl := pflag.Uint("x-sl", 0, "[Advanced] Schrödinger level")
-	resolveTimeout := pflag.Duration("x-timeout", 0, "[Advanced] Resolution timeout")
-	buildBoardTimeout := pflag.Duration("x-build-timeout", 5*time.Minute, "[Advanced] Build timeout per resolution")
-	reduceBoardTimeout := pflag.Duration("x-reduce-timeout", 20*time.Minute, "[Advanced] Reduction timeout")
-	buildMinRatio := pflag.Uint("x-new-min-ratio", 55, "[Advanced] Build empty cell ratio (40-60)")
-	buildMaxRatio := pflag.Uint("x-new-max-ratio", 62, "[Advanced] Build empty cell ratio (50-99)")
-	all := pflag.Bool("all", false, "Look for all possible solutions")
-	reduce := pflag.Bool("reduce", false, "Try to reduce the number of digits")
-	buildNewSize := pflag.Uint("new", 0, "Build a new takuzu board (with given size)")
-	pdfFileName := pflag.String("to-pdf", "", "PDF output file name")
-	workers := pflag.Uint("workers", 1, "Number of parallel workers (use with --new)")
-
-	pflag.Parse()
-
-	verbosity = int(*vbl)
+// apply initializes the global settings from the common flags
+func (cf *commonFlags) apply() error {
+	verbosity = int(*cf.verbosity)
 	takuzu.SetVerbosityLevel(verbosity)
-	takuzu.SetSchrodingerLevel(*schrodLvl)
-
-	var tak *takuzu.Takuzu
+	takuzu.SetSchrodingerLevel(*cf.schrodLvl)
 
-	if *board != "" {
-		var err error
-		tak, err = takuzu.NewFromString(*board)
-		if tak == nil || err != nil {
-			fmt.Fprintln(os.Stderr, "Error:", err)
-			tak = nil
-		}
+	switch *cf.format {
+	case "text", "html", "markdown", "json":
+		outputFormat = *cf.format
+	default:
+		return errors.Errorf("unknown output format '%s'", *cf.format)
 	}
+	return nil
+}
 
-	if *buildNewSize > 0 {
-		if verbosity > 1 {
-			log.Printf("buildBoardTimeout:   %v", *buildBoardTimeout)
-			log.Printf("reduceBoardTimeout:  %v", *reduceBoardTimeout)
-			log.Printf("Free cell min ratio: %v", *buildMinRatio)
-			log.Printf("Free cell max ratio: %v", *buildMaxRatio)
+// parseFlags parses the command line flags and applies the common flags.
+// It returns a non-negative exit code if the command should stop.
+func parseFlags(fs *pflag.FlagSet, cf *commonFlags, args []string) int {
+	if err := fs.Parse(args); err != nil {
+		if err == pflag.ErrHelp {
+			return exitOK
 		}
-		tak = newTakuzuGameBoard(int(*buildNewSize), *simple,
-			int(*workers),
-			*buildBoardTimeout, *reduceBoardTimeout,
-			int(*buildMinRatio), int(*buildMaxRatio))
+		return exitUsage
 	}
-
-	if tak == nil {
-		fmt.Fprintln(os.Stderr, "Could not create takuzu board.")
-		os.Exit(255)
+	if err := cf.apply(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitUsage
 	}
+	return -1
+}
 
-	tak.DumpBoard()
-	fmt.Println()
-
-	if *pdfFileName != "" {
-		if err := tak2pdf(tak, *pdfFileName); err != nil {
-			log.Println(err)
-			os.Exit(1)
-		}
-		if *out {
-			tak.DumpString()
-		}
-		os.Exit(0)
+// loadBoard returns the board from the --board flag or from the first
+// argument.  If the board string is "-", the first valid board string is
+// read from the standard input.
+func loadBoard(fs *pflag.FlagSet, board string) (*takuzu.Takuzu, error) {
+	if board == "" {
+		board = fs.Arg(0)
 	}
-
-	if *buildNewSize > 0 {
-		if *out {
-			tak.DumpString()
-		}
-		os.Exit(0)
+	if board == "" {
+		return nil, errNoBoard
 	}
-
-	if *reduce {
-		if verbosity > 1 {
-			log.Printf("buildBoardTimeout:   %v", *buildBoardTimeout)
-			log.Printf("reduceBoardTimeout:  %v", *reduceBoardTimeout)
-		}
-		var err error
-		if tak, err = tak.ReduceBoard(*simple, "0", *buildBoardTimeout, *reduceBoardTimeout); err != nil {
-			log.Println(err)
-			os.Exit(1)
-		}
-
-		tak.DumpBoard()
-		fmt.Println()
-
-		if *out {
-			tak.DumpString()
-		}
-
-		os.Exit(0)
+	if board != "-" {
+		return takuzu.NewFromString(board)
 	}
 
-	if *simple {
-		full, err := tak.TrySolveTrivial()
-		if err != nil {
-			log.Println(err)
-			os.Exit(1)
-		}
-		if !full {
-			tak.DumpBoard()
-			fmt.Println()
-			if *out {
-				tak.DumpString()
-			}
-			log.Println("The takuzu could not be completed using trivial methods.")
-			os.Exit(2)
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		e, err := takuzu.ParseCollectionLine(strings.TrimSpace(scanner.Text()))
+		if err == nil && e != nil {
+			return e.Board, nil
 		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("no board found in standard input")
+}
 
-		log.Println("The takuzu is correct and complete.")
-		tak.DumpBoard()
-		fmt.Println()
+// errNoBoard is returned by loadBoard when no board is given
+var errNoBoard = errors.New("no board")
 
-		if *out {
-			tak.DumpString()
-		}
-		os.Exit(0)
+// loadBoardExitCode returns the exit code for a loadBoard error: a missing
+// board is a usage error, and a board that cannot be read is invalid.
+func loadBoardExitCode(err error) int {
+	if err == errNoBoard {
+		return exitUsage
 	}
+	return exitError
+}
 
-	var allSol *[]takuzu.Takuzu
-	if *all {
-		allSol = &[]takuzu.Takuzu{}
-	}
-	res, err := tak.TrySolveRecurse(allSol, *resolveTimeout)
-	if err != nil && verbosity > 1 {
-		// The last trivial resolution failed
-		log.Println("Trivial resolution failed:", err)
-	}
+// isTimeout returns true if the error is a resolution timeout
+func isTimeout(err error) bool {
+	return err != nil && errors.Cause(err).Error() == "timeout"
+}
 
-	// Ignoring res & err if a full search was requested
-	if *all {
-		log.Println(len(*allSol), "solution(s) found.")
-		if len(*allSol) > 0 {
-			for _, s := range *allSol {
-				if *out {
-					s.DumpString()
-				} else {
-					s.DumpBoard()
-					fmt.Println()
-				}
-			}
-			if len(*allSol) > 1 {
-				os.Exit(3)
-			}
-			os.Exit(0)
-		}
-		fmt.Println("No solution found.")
-		os.Exit(2)
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitUsage)
 	}
 
-	if err != nil {
-		log.Println(err)
-		os.Exit(1)
+	name := os.Args[1]
+	switch name {
+	case "help", "-h", "--help":
+		usage()
+		os.Exit(exitOK)
 	}
-	if res != nil {
-		res.DumpBoard()
-		fmt.Println()
 
-		if *out {
-			res.DumpString()
+	for _, c := range commands {
+		if c.name == name {
+			os.Exit(c.run(c.newFlagSet(), os.Args[2:]))
 		}
-		os.Exit(0)
 	}
 
-	fmt.Println("No solution found.")
-	os.Exit(2)
+	fmt.Fprintf(os.Stderr, "Error: unknown command '%s'\n\n", name)
+	usage()
+	os.Exit(exitUsage)
 }
@@ -0,0 +1,88 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/McKael/takuzu"
+)
+
+var gradeCommand = &command{
+	name:        "grade",
+	args:        "[BOARD]",
+	description: "Evaluate the difficulty of a puzzle",
+	exitCodes: []string{
+		"0  The puzzle was graded",
+		"1  The board is invalid",
+		"2  The puzzle has no solution",
+		"3  The puzzle has several solutions",
+		"4  Timeout",
+	},
+	run: runGrade,
+}
+
+func runGrade(fs *pflag.FlagSet, args []string) int {
+	cf := addCommonFlags(fs)
+	board := fs.String("board", "", "Board string (\"-\" to read it from the standard input)")
+	resolveTimeout := fs.Duration("x-timeout", 0, "[Advanced] Resolution timeout")
+	depth := fs.Int("depth", 2, "Maximum probing depth")
+	steps := fs.Bool("steps", false, "Display the deductions of the probing solver")
+
+	if code := parseFlags(fs, cf, args); code >= 0 {
+		return code
+	}
+
+	rep := newReport("grade")
+
+	if *depth < 0 {
+		return rep.done("error", errors.New("the probing depth cannot be negative"), exitUsage)
+	}
+
+	tak, err := loadBoard(fs, *board)
+	if err != nil {
+		return rep.done("error", err, loadBoardExitCode(err))
+	}
+	rep.setBoard(tak)
+
+	d, err := tak.Grade(*resolveTimeout)
+	if err != nil {
+		textln("Could not grade the puzzle:", err)
+		rep.Error = err.Error()
+		switch {
+		case isTimeout(err):
+			return rep.done("timeout", nil, exitTimeout)
+		case errors.Cause(err) == takuzu.ErrNoSolution:
+			return rep.done("unsolvable", nil, exitNoSolution)
+		case errors.Cause(err) == takuzu.ErrMultipleSolutions:
+			return rep.done("multiple", nil, exitMultiple)
+		}
+		return rep.done("invalid", nil, exitError)
+	}
+	textln(d)
+	rep.Difficulty = d.String()
+
+	probeSteps, full, err := tak.Clone().SolveProbe(*depth)
+	if err != nil {
+		return rep.done("error", errors.Wrap(err, "probing failed"), exitError)
+	}
+	pd := takuzu.StepsDepth(probeSteps, full)
+	if pd >= 0 {
+		textln("Probing depth:", pd)
+	} else {
+		textln("Probing depth: more than", *depth)
+	}
+	rep.Depth = &pd
+	if *steps {
+		rep.Steps = probeSteps
+		for _, s := range rep.Steps {
+			textln(fmt.Sprintf("[%d,%d] = %d (depth %d)", s.Line, s.Col, s.Value, s.Depth))
+		}
+	}
+	return rep.done("graded", nil, exitOK)
+}
@@ -0,0 +1,97 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/McKael/takuzu"
+)
+
+var hintCommand = &command{
+	name:        "hint",
+	args:        "[BOARD]",
+	description: "Give a hint about a cell that can be deduced, or about a mistake",
+	exitCodes: []string{
+		"0  A hint was found",
+		"1  The board is invalid, breaks the rules or doesn't match the puzzle",
+		"2  No hint could be found",
+		"4  Timeout (while looking for mistakes)",
+	},
+	run: runHint,
+}
+
+func runHint(fs *pflag.FlagSet, args []string) int {
+	cf := addCommonFlags(fs)
+	board := fs.String("board", "", "Board string (\"-\" to read it from the standard input)")
+	puzzleString := fs.String("puzzle", "", "Initial puzzle string, used to look for mistakes")
+	level := fs.Uint("level", uint(takuzu.HintCell), "Hint level (1: region, 2: technique, 3: cell)")
+	resolveTimeout := fs.Duration("x-timeout", 0, "[Advanced] Resolution timeout")
+
+	if code := parseFlags(fs, cf, args); code >= 0 {
+		return code
+	}
+
+	rep := newReport("hint")
+
+	if *level < uint(takuzu.HintRegion) || *level > uint(takuzu.HintCell) {
+		return rep.done("error", errors.New("invalid hint level"), exitUsage)
+	}
+
+	tak, err := loadBoard(fs, *board)
+	if err != nil {
+		return rep.done("error", err, loadBoardExitCode(err))
+	}
+	rep.setBoard(tak)
+
+	var hint *takuzu.Hint
+	if *puzzleString != "" {
+		puzzle, err := takuzu.NewFromString(*puzzleString)
+		if err != nil {
+			return rep.done("error", errors.Wrap(err, "invalid puzzle"), exitUsage)
+		}
+		if puzzle.Size != tak.Size {
+			return rep.done("error", errors.New("puzzle and board sizes do not match"), exitUsage)
+		}
+		if match, _, _ := takuzu.BoardsMatch(puzzle, tak, true); !match {
+			textln("The board doesn't match the puzzle.")
+			return rep.done("invalid", errors.New("the board doesn't match the puzzle"), exitError)
+		}
+		game := takuzu.NewGame(*puzzle)
+		game.SetState(*tak)
+		if hint, err = game.Hint(*resolveTimeout); err != nil {
+			textln("Could not look for mistakes:", err)
+			rep.Error = err.Error()
+			if isTimeout(err) {
+				return rep.done("timeout", nil, exitTimeout)
+			}
+			return rep.done("invalid", nil, exitError)
+		}
+	}
+
+	if hint == nil {
+		if _, err := tak.Validate(); err != nil {
+			textln("Invalid board:", err)
+			rep.Error = err.Error()
+			return rep.done("invalid", nil, exitError)
+		}
+		hint = tak.Hint()
+	}
+
+	if hint == nil {
+		textln("No hint found.")
+		return rep.done("none", nil, exitNoSolution)
+	}
+
+	msg := hint.Message(takuzu.HintLevel(*level))
+	textln(msg)
+	rep.Hint = newHintReport(hint, takuzu.HintLevel(*level))
+	status := "hint"
+	if hint.Technique == takuzu.TechniqueMistake {
+		status = "mistake"
+	}
+	return rep.done(status, nil, exitOK)
+}
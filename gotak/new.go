@@ -0,0 +1,164 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/McKael/takuzu"
+)
+
+var newCommand = &command{
+	name:        "new",
+	args:        "[SIZE]",
+	description: "Build a new takuzu puzzle",
+	exitCodes: []string{
+		"0  A new board was built",
+		"1  The board could not be built",
+	},
+	run: runNew,
+}
+
+// buildFlags are the flags used to tune board generation.
+// Only the timeouts are set when the flags are registered with
+// addTimeoutFlags.
+type buildFlags struct {
+	buildBoardTimeout  *time.Duration
+	reduceBoardTimeout *time.Duration
+	minRatio, maxRatio *uint
+	rowPatterns        *bool
+}
+
+// addTimeoutFlags registers the resolution timeout flags, used to reduce
+// existing boards
+func addTimeoutFlags(fs *pflag.FlagSet) *buildFlags {
+	return &buildFlags{
+		buildBoardTimeout:  fs.Duration("x-build-timeout", 5*time.Minute, "[Advanced] Build timeout per resolution"),
+		reduceBoardTimeout: fs.Duration("x-reduce-timeout", 20*time.Minute, "[Advanced] Reduction timeout"),
+	}
+}
+
+func addBuildFlags(fs *pflag.FlagSet) *buildFlags {
+	bf := addTimeoutFlags(fs)
+	bf.minRatio = fs.Uint("x-new-min-ratio", takuzu.DefaultMinRatio, "[Advanced] Build empty cell ratio (40-60)")
+	bf.maxRatio = fs.Uint("x-new-max-ratio", takuzu.DefaultMaxRatio, "[Advanced] Build empty cell ratio (50-99)")
+	bf.rowPatterns = fs.Bool("x-row-patterns", false, "[Advanced] Build the puzzles from random complete boards (assembled from valid line patterns up to 24x24)")
+	return bf
+}
+
+// options returns the library build options; the build is canceled with
+// the context
+func (bf *buildFlags) options(ctx context.Context, size int, simple bool, wid string) takuzu.BuildOptions {
+	return takuzu.BuildOptions{
+		Size:          size,
+		Simple:        simple,
+		MinRatio:      int(*bf.minRatio),
+		MaxRatio:      int(*bf.maxRatio),
+		BuildTimeout:  *bf.buildBoardTimeout,
+		ReduceTimeout: *bf.reduceBoardTimeout,
+		RowPatterns:   *bf.rowPatterns,
+		ID:            wid,
+		Context:       ctx,
+	}
+}
+
+func (bf *buildFlags) logSettings() {
+	if verbosity > 1 {
+		log.Printf("buildBoardTimeout:   %v", *bf.buildBoardTimeout)
+		log.Printf("reduceBoardTimeout:  %v", *bf.reduceBoardTimeout)
+		if bf.minRatio == nil {
+			return
+		}
+		log.Printf("Free cell min ratio: %v", *bf.minRatio)
+		log.Printf("Free cell max ratio: %v", *bf.maxRatio)
+		log.Printf("Row patterns:        %v", *bf.rowPatterns)
+	}
+}
+
+func newTakuzuGameBoard(jobs int, bf *buildFlags, size int, simple bool) *takuzu.Takuzu {
+	if jobs == 0 {
+		return nil
+	}
+
+	// The other workers are canceled once a board has been built.
+	// The channel is buffered so that the workers whose result is not
+	// used can terminate.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := make(chan *takuzu.Takuzu, jobs)
+
+	newTak := func(i int) {
+		takuzu, err := takuzu.NewRandomTakuzuWithOptions(bf.options(ctx, size, simple, fmt.Sprintf("%v", i)))
+
+		if err == nil && takuzu != nil {
+			results <- takuzu
+			if verbosity > 0 && jobs > 1 {
+				log.Printf("Worker #%d done.", i)
+			}
+		} else {
+			results <- nil
+		}
+	}
+
+	for i := 0; i < jobs; i++ {
+		go newTak(i)
+	}
+	// A failed worker does not stop the others
+	for i := 0; i < jobs; i++ {
+		if tak := <-results; tak != nil {
+			return tak
+		}
+	}
+	return nil
+}
+
+func runNew(fs *pflag.FlagSet, args []string) int {
+	cf := addCommonFlags(fs)
+	bf := addBuildFlags(fs)
+	size := fs.Uint("size", 0, "Board size")
+	simple := fs.Bool("simple", false, "Build a board that can be solved with trivial methods")
+	out := fs.Bool("out", false, "Send board string to output")
+	workers := fs.Uint("workers", 1, "Number of parallel workers")
+
+	if code := parseFlags(fs, cf, args); code >= 0 {
+		return code
+	}
+
+	rep := newReport("new")
+
+	if *size == 0 && fs.NArg() > 0 {
+		n, err := strconv.ParseUint(fs.Arg(0), 10, 0)
+		if err != nil {
+			return rep.done("error", errors.New("invalid board size"), exitUsage)
+		}
+		*size = uint(n)
+	}
+	if *size == 0 {
+		return rep.done("error", errors.New("no board size"), exitUsage)
+	}
+
+	bf.logSettings()
+	tak := newTakuzuGameBoard(int(*workers), bf, int(*size), *simple)
+
+	if tak == nil {
+		return rep.done("error", errors.New("could not create takuzu board"), exitError)
+	}
+
+	printBoard(tak, nil)
+
+	if *out {
+		printString(tak)
+	}
+	rep.Result = tak.ToString()
+	rep.Stats = newBoardStats(tak)
+	return rep.done("generated", nil, exitOK)
+}
@@ -0,0 +1,192 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/McKael/takuzu"
+)
+
+// outputFormat is the format used to display boards
+// (text, html, markdown or json)
+var outputFormat = "text"
+
+// jsonOutput returns true if the command results should be written as a
+// JSON document
+func jsonOutput() bool {
+	return outputFormat == "json"
+}
+
+// printBoard displays a board using the selected output format.
+// If puzzle is not nil, the cells that are not given in the puzzle are
+// displayed differently when the format allows it.
+// Nothing is displayed in JSON mode.
+func printBoard(tak, puzzle *takuzu.Takuzu) {
+	var err error
+	switch outputFormat {
+	case "json":
+		return
+	case "html":
+		err = tak.WriteHTML(os.Stdout, takuzu.HTMLOptions{Puzzle: puzzle, ShowErrors: true})
+	case "markdown":
+		err = tak.WriteMarkdown(os.Stdout, takuzu.MarkdownOptions{Puzzle: puzzle, ShowErrors: true})
+	default:
+		tak.DumpBoard()
+	}
+	if err != nil {
+		log.Println(err)
+	}
+	fmt.Println()
+}
+
+// printString displays the board string, unless in JSON mode
+func printString(tak *takuzu.Takuzu) {
+	if !jsonOutput() {
+		tak.DumpString()
+	}
+}
+
+// textln prints a message to the standard output, unless in JSON mode
+func textln(a ...interface{}) {
+	if !jsonOutput() {
+		fmt.Println(a...)
+	}
+}
+
+// boardStats contains statistics about a board
+type boardStats struct {
+	Size   int `json:"size"`
+	Givens int `json:"givens"`
+	Empty  int `json:"empty"`
+}
+
+func newBoardStats(tak *takuzu.Takuzu) *boardStats {
+	s := &boardStats{Size: tak.Size}
+	for _, l := range tak.Board {
+		for _, c := range l {
+			if c.Defined {
+				s.Givens++
+			} else {
+				s.Empty++
+			}
+		}
+	}
+	return s
+}
+
+// hintReport is the JSON representation of a hint.
+// The fields that are not revealed at the requested level are omitted.
+type hintReport struct {
+	Level     int    `json:"level"`
+	Message   string `json:"message"`
+	Region    string `json:"region"`
+	Technique string `json:"technique,omitempty"`
+	Line      *int   `json:"line,omitempty"`
+	Col       *int   `json:"col,omitempty"`
+	Value     *int   `json:"value,omitempty"`
+}
+
+func newHintReport(h *takuzu.Hint, level takuzu.HintLevel) *hintReport {
+	hr := &hintReport{
+		Level:   int(level),
+		Message: h.Message(level),
+		Region:  h.Region.String(),
+	}
+	if level >= takuzu.HintTechnique {
+		hr.Technique = h.Technique.String()
+	}
+	if level >= takuzu.HintCell {
+		line, col, value := h.Line, h.Col, h.Value
+		hr.Line, hr.Col, hr.Value = &line, &col, &value
+	}
+	return hr
+}
+
+// checkReport is the JSON representation of the check of a board against
+// the solution of the puzzle
+type checkReport struct {
+	Unique     bool              `json:"unique"`
+	Consistent bool              `json:"consistent"`
+	WrongCells []takuzu.Position `json:"wrong_cells,omitempty"`
+}
+
+// report is the JSON document written by the commands in JSON mode
+type report struct {
+	Command       string             `json:"command"`
+	Line          int                `json:"line,omitempty"` // Input line number (batch mode)
+	Board         string             `json:"board,omitempty"`
+	Status        string             `json:"status"`
+	Error         string             `json:"error,omitempty"`
+	Result        string             `json:"result,omitempty"`
+	Solutions     []string           `json:"solutions,omitempty"`
+	SolutionCount *int               `json:"solution_count,omitempty"`
+	Hint          *hintReport        `json:"hint,omitempty"`
+	Check         *checkReport       `json:"check,omitempty"`
+	Difficulty    string             `json:"difficulty,omitempty"`
+	Depth         *int               `json:"depth,omitempty"` // Probing depth (-1: too deep)
+	Steps         []takuzu.ProbeStep `json:"steps,omitempty"`
+	Files         []string           `json:"files,omitempty"`
+	Count         int                `json:"count,omitempty"` // Number of generated puzzles
+	Elapsed       float64            `json:"elapsed"`         // Seconds
+	Stats         *boardStats        `json:"stats,omitempty"`
+
+	start time.Time
+}
+
+func newReport(cmd string) *report {
+	return &report{Command: cmd, start: time.Now()}
+}
+
+// setBoard sets the input board of the report
+func (r *report) setBoard(tak *takuzu.Takuzu) {
+	r.Board = tak.ToString()
+	r.Stats = newBoardStats(tak)
+}
+
+// setSolutions sets the list of solutions and the solution count
+func (r *report) setSolutions(solutions []takuzu.Takuzu) {
+	n := len(solutions)
+	r.SolutionCount = &n
+	r.Solutions = make([]string, n)
+	for i, s := range solutions {
+		r.Solutions[i] = s.ToString()
+	}
+}
+
+// finish sets the status, the error and the elapsed time of the report
+func (r *report) finish(status string, err error) {
+	r.Status = status
+	if err != nil {
+		r.Error = err.Error()
+	}
+	r.Elapsed = time.Since(r.start).Seconds()
+}
+
+// done completes the report with the status and returns the exit code.
+// The report is written to the standard output in JSON mode; otherwise
+// the error, if any, is logged.
+func (r *report) done(status string, err error, code int) int {
+	r.finish(status, err)
+
+	if !jsonOutput() {
+		if err != nil {
+			log.Println(err)
+		}
+		return code
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		log.Println(err)
+		return exitError
+	}
+	return code
+}
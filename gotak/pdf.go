@@ -6,6 +6,10 @@ package main
 
 import (
 	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/jung-kurt/gofpdf"
 	"github.com/pkg/errors"
@@ -13,50 +17,256 @@ import (
 	"github.com/McKael/takuzu"
 )
 
-func tak2pdf(takuzu *takuzu.Takuzu, pdfFileName string) error {
+// pdfOptions contains the page and board style parameters of PDF files
+type pdfOptions struct {
+	pageSize    string // A3, A4, A5, Letter or Legal
+	landscape   bool
+	blockSize   int  // Draw thicker lines every blockSize cells (0 to disable)
+	shadeGivens bool // Shade the given cells
+	cellSize    float64
+}
+
+var pdfPageSizes = []string{"A3", "A4", "A5", "Letter", "Legal"}
+
+// newPDF creates a new PDF document with the requested page format
+func newPDF(opts pdfOptions) (*gofpdf.Fpdf, error) {
+	pageSize := ""
+	for _, ps := range pdfPageSizes {
+		if strings.EqualFold(ps, opts.pageSize) {
+			pageSize = ps
+		}
+	}
+	if pageSize == "" {
+		return nil, errors.Errorf("unknown page size '%s'", opts.pageSize)
+	}
+	orientation := "P"
+	if opts.landscape {
+		orientation = "L"
+	}
+	pdf := gofpdf.New(orientation, "mm", pageSize, "")
+	pdf.SetAutoPageBreak(false, 0)
+	return pdf, nil
+}
+
+func tak2pdf(takuzu *takuzu.Takuzu, pdfFileName string, opts pdfOptions) error {
 
 	if pdfFileName == "" {
 		return errors.New("no PDF file name")
 	}
 
-	size := takuzu.Size
+	pdf, err := newPDF(opts)
+	if err != nil {
+		return err
+	}
+
+	// Shrink the cells if the board does not fit on the page
+	cs := opts.cellSize
+	pageW, pageH := pdf.GetPageSize()
+	avail := pageW
+	if pageH < avail {
+		avail = pageH
+	}
+	avail -= 2 * pdfMargin
+	if cs*float64(takuzu.Size) > avail {
+		cs = avail / float64(takuzu.Size)
+	}
 
-	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
 	pdf.SetFont("Arial", "", 14)
+	pdfBoard(pdf, takuzu, nil, pdfMargin, pdfMargin, cs, opts)
+	if err := pdf.OutputFileAndClose(pdfFileName); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// bookletOptions contains the layout parameters of a PDF booklet
+type bookletOptions struct {
+	title         string
+	columns, rows int // Number of puzzles per page
+	answerColumns int // Number of solutions per line on answer pages
+	pdfOptions
+}
+
+// bookletPuzzle is a puzzle with its booklet metadata
+type bookletPuzzle struct {
+	puzzle     *takuzu.Takuzu
+	solution   *takuzu.Takuzu
+	difficulty takuzu.Difficulty
+}
+
+const (
+	pdfMargin       = 15.0 // mm
+	pdfHeaderHeight = 10.0 // mm
+	pdfLabelHeight  = 7.0  // mm
+)
+
+// pdfBoard draws a board at the given position with the given cell size (mm).
+// If puzzle is not nil, only the cells defined in the puzzle are considered as
+// given cells.  Given cells are written in bold and can be shaded.
+func pdfBoard(pdf *gofpdf.Fpdf, tak, puzzle *takuzu.Takuzu, x, y, cellSize float64, opts pdfOptions) {
+	size := tak.Size
+	width := float64(size) * cellSize
 
-	basicTable := func() {
-
-		for ln, l := range takuzu.Board {
-			for cn, cell := range l {
-				border := "" // empty, "1", "L", "T", "R" and "B"
-				if ln == 0 {
-					border += "T"
-				}
-				if cn == 0 {
-					border += "L"
-				}
-				if ln+1 == size {
-					border += "B"
-				}
-				if cn+1 == size {
-					border += "R"
-				}
-				align := "CM" // horiz=Center vert=Middle
-				if cell.Defined {
-					pdf.CellFormat(8, 8, fmt.Sprint(cell.Value), border, 0, align, false, 0, "")
-				} else {
-					pdf.CellFormat(8, 8, ".", border, 0, align, false, 0, "")
-				}
+	pdf.SetFontSize(cellSize * 0.6 * 72 / 25.4) // 60% of the cell height, in points
+	pdf.SetFillColor(0xd8, 0xd8, 0xd8)
+	pdf.SetLineWidth(0.2)
+	for ln, l := range tak.Board {
+		pdf.SetXY(x, y+float64(ln)*cellSize)
+		for cn, cell := range l {
+			if !cell.Defined {
+				pdf.CellFormat(cellSize, cellSize, "", "1", 0, "CM", false, 0, "")
+				continue
 			}
-			pdf.Ln(-1)
+			given := puzzle == nil || puzzle.Board[ln][cn].Defined
+			if given {
+				pdf.SetFontStyle("B")
+			} else {
+				pdf.SetFontStyle("")
+			}
+			pdf.CellFormat(cellSize, cellSize, fmt.Sprint(cell.Value), "1", 0, "CM",
+				given && opts.shadeGivens, 0, "")
 		}
 	}
+	pdf.SetFontStyle("")
 
-	pdf.AddPage()
-	basicTable()
-	if err := pdf.OutputFileAndClose(pdfFileName); err != nil {
+	// Block separators
+	if opts.blockSize > 0 && opts.blockSize < size {
+		pdf.SetLineWidth(0.5)
+		for i := opts.blockSize; i < size; i += opts.blockSize {
+			p := float64(i) * cellSize
+			pdf.Line(x+p, y, x+p, y+width)
+			pdf.Line(x, y+p, x+width, y+p)
+		}
+	}
+
+	// Outer border
+	pdf.SetLineWidth(0.6)
+	pdf.Rect(x, y, width, width, "D")
+	pdf.SetLineWidth(0.2)
+}
+
+// pdfGrid lays out boards on the pages, starting with a new page.
+// The boards are drawn by the draw callback, which receives the index
+// of the board, the position of the slot, and the slot width and height.
+func pdfGrid(pdf *gofpdf.Fpdf, n, columns, rows int, header string,
+	draw func(i int, x, y, w, h float64)) {
+	pageW, pageH := pdf.GetPageSize()
+	slotW := (pageW - 2*pdfMargin) / float64(columns)
+	slotH := (pageH - 2*pdfMargin - pdfHeaderHeight) / float64(rows)
+
+	perPage := columns * rows
+	for i := 0; i < n; i++ {
+		if i%perPage == 0 {
+			pdf.AddPage()
+			pdf.SetFont("Arial", "B", 14)
+			pdf.SetXY(pdfMargin, pdfMargin)
+			pdf.CellFormat(pageW-2*pdfMargin, pdfHeaderHeight, header, "", 0, "CT", false, 0, "")
+		}
+		slot := i % perPage
+		x := pdfMargin + float64(slot%columns)*slotW
+		y := pdfMargin + pdfHeaderHeight + float64(slot/columns)*slotH
+		draw(i, x, y, slotW, slotH)
+	}
+}
+
+// boardSlot returns the position and cell size of a board centered in a
+// slot, leaving some room for a label above the board.
+func boardSlot(size int, x, y, w, h float64) (bx, by, cellSize float64) {
+	avail := h - pdfLabelHeight
+	if w < avail {
+		avail = w
+	}
+	avail *= 0.9 // Spacing between boards
+	cellSize = avail / float64(size)
+	width := cellSize * float64(size)
+	bx = x + (w-width)/2
+	by = y + pdfLabelHeight
+	return
+}
+
+// tak2pdfBooklet writes a booklet with several puzzles per page, followed
+// by answer-key pages.
+func tak2pdfBooklet(puzzles []bookletPuzzle, pdfFileName string, opts bookletOptions) error {
+	if pdfFileName == "" {
+		return errors.New("no PDF file name")
+	}
+	if len(puzzles) == 0 {
+		return errors.New("no puzzle")
+	}
+	if opts.columns < 1 || opts.rows < 1 || opts.answerColumns < 1 {
+		return errors.New("invalid booklet layout")
+	}
+
+	pdf, err := newPDF(opts.pdfOptions)
+	if err != nil {
 		return err
 	}
+	pdf.SetTitle(opts.title, true)
 
-	return nil
+	// Puzzle pages
+	pdfGrid(pdf, len(puzzles), opts.columns, opts.rows, opts.title,
+		func(i int, x, y, w, h float64) {
+			p := puzzles[i]
+			bx, by, cs := boardSlot(p.puzzle.Size, x, y, w, h)
+			pdf.SetFont("Arial", "B", 11)
+			label := fmt.Sprintf("#%d - %dx%d - %s", i+1, p.puzzle.Size, p.puzzle.Size, p.difficulty)
+			pdf.SetXY(bx, y)
+			pdf.CellFormat(cs*float64(p.puzzle.Size), pdfLabelHeight, label, "", 0, "LM", false, 0, "")
+			pdf.SetFont("Arial", "", 12)
+			pdfBoard(pdf, p.puzzle, nil, bx, by, cs, opts.pdfOptions)
+		})
+
+	// Answer-key pages
+	pageW, pageH := pdf.GetPageSize()
+	slotW := (pageW - 2*pdfMargin) / float64(opts.answerColumns)
+	answerRows := int((pageH - 2*pdfMargin - pdfHeaderHeight) / slotW)
+	if answerRows < 1 {
+		answerRows = 1
+	}
+	pdfGrid(pdf, len(puzzles), opts.answerColumns, answerRows, "Solutions",
+		func(i int, x, y, w, h float64) {
+			p := puzzles[i]
+			bx, by, cs := boardSlot(p.solution.Size, x, y, w, h)
+			pdf.SetFont("Arial", "B", 9)
+			pdf.SetXY(bx, y)
+			pdf.CellFormat(cs*float64(p.solution.Size), pdfLabelHeight, fmt.Sprintf("#%d", i+1), "", 0, "LM", false, 0, "")
+			pdf.SetFont("Arial", "", 8)
+			pdfBoard(pdf, p.solution, p.puzzle, bx, by, cs, opts.pdfOptions)
+		})
+
+	return pdf.OutputFileAndClose(pdfFileName)
+}
+
+// loadBooklet reads a collection file and prepares the booklet puzzles,
+// i.e. it computes the solution and the difficulty of each puzzle.
+func loadBooklet(collectionFileName string, timeout time.Duration) ([]bookletPuzzle, error) {
+	f, err := os.Open(collectionFileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries, err := takuzu.ReadCollection(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var puzzles []bookletPuzzle
+	for i, e := range entries {
+		if verbosity > 0 {
+			log.Printf("Booklet: processing puzzle #%d", i+1)
+		}
+		d, sol, err := e.Board.GradeWithSolution(takuzu.SolveOptions{Timeout: timeout})
+		if err != nil {
+			return nil, errors.Wrapf(err, "puzzle #%d", i+1)
+		}
+		puzzles = append(puzzles, bookletPuzzle{
+			puzzle:     e.Board,
+			solution:   sol,
+			difficulty: d,
+		})
+	}
+	return puzzles, nil
 }
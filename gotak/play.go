@@ -0,0 +1,443 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package main
+
+// This file contains the interactive terminal game.
+// The terminal is driven with ANSI escape sequences; the raw mode is set up
+// with stty(1) so that no terminal library is required.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/McKael/takuzu"
+)
+
+var playCommand = &command{
+	name:        "play",
+	args:        "[BOARD]",
+	description: "Play a takuzu board in the terminal",
+	exitCodes: []string{
+		"0  The game was completed or saved",
+		"1  The game could not be started or saved",
+		"2  The game was left unfinished",
+	},
+	run: runPlay,
+}
+
+const playHelp = "arrows/hjkl: move  space: toggle  0/1/x: set/clear  u/r: undo/redo  ?: hint  v: check  s: save  q: quit\r\n" +
+	"m: tentative mark  n: exclude value  a: auto candidates  c: clear marks"
+
+// ANSI escape sequences
+const (
+	ansiClear   = "\x1b[H\x1b[2J"
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiReverse = "\x1b[7m"
+	ansiRed     = "\x1b[31m"
+	ansiBlue    = "\x1b[34m"
+	ansiDim     = "\x1b[2m"
+	ansiHideCur = "\x1b[?25l"
+	ansiShowCur = "\x1b[?25h"
+)
+
+// Keys
+const (
+	keyUp = iota + 256
+	keyDown
+	keyLeft
+	keyRight
+)
+
+// playSession is the state of an interactive game
+type playSession struct {
+	game      *takuzu.Game
+	line, col int           // Cursor position
+	elapsed   time.Duration // Time spent in previous sessions
+	start     time.Time
+	message   string
+	saveFile  string
+	completed bool
+
+	hint      *takuzu.Hint // Current hint
+	hintLevel takuzu.HintLevel
+	wrong     []takuzu.Position // Wrong cells found by the last check
+}
+
+// playHintTimeout is the timeout used to solve the puzzle when looking for
+// mistakes
+const playHintTimeout = 10 * time.Second
+
+// savedGame is the JSON format of a saved game
+type savedGame struct {
+	Game    *takuzu.Game `json:"game"`
+	Elapsed float64      `json:"elapsed"` // Seconds
+}
+
+func runPlay(fs *pflag.FlagSet, args []string) int {
+	cf := addCommonFlags(fs)
+	board := fs.String("board", "", "Board string")
+	saveFile := fs.String("save-file", "takuzu-game.json", "File used to save the game")
+	resume := fs.Bool("resume", false, "Resume the game saved in the save file")
+
+	if code := parseFlags(fs, cf, args); code >= 0 {
+		return code
+	}
+
+	s := &playSession{saveFile: *saveFile}
+
+	if *resume {
+		if err := s.load(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return exitError
+		}
+	} else {
+		tak, err := loadBoard(fs, *board)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return loadBoardExitCode(err)
+		}
+		s.game = takuzu.NewGame(*tak)
+	}
+
+	if err := s.run(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitError
+	}
+	if s.completed {
+		fmt.Printf("Completed in %v.\n", s.playTime().Round(time.Second))
+		return exitOK
+	}
+	return exitNoSolution
+}
+
+// playTime returns the total time spent on the game
+func (s *playSession) playTime() time.Duration {
+	return s.elapsed + time.Since(s.start)
+}
+
+func (s *playSession) load() error {
+	data, err := os.ReadFile(s.saveFile)
+	if err != nil {
+		return err
+	}
+	var sg savedGame
+	if err := json.Unmarshal(data, &sg); err != nil {
+		return errors.Wrap(err, "invalid saved game")
+	}
+	if sg.Game == nil {
+		return errors.New("invalid saved game")
+	}
+	s.game = sg.Game
+	s.elapsed = time.Duration(sg.Elapsed * float64(time.Second))
+	return nil
+}
+
+func (s *playSession) save() error {
+	sg := savedGame{
+		Game:    s.game,
+		Elapsed: s.playTime().Seconds(),
+	}
+	data, err := json.MarshalIndent(sg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.saveFile, data, 0644)
+}
+
+// set changes the value of the cell under the cursor
+func (s *playSession) set(value int) {
+	if err := s.game.Play(s.line, s.col, value); err != nil {
+		s.message = "Cannot change this cell: " + err.Error() + "."
+	}
+}
+
+// annotate toggles pencil marks of the cell under the cursor
+func (s *playSession) annotate(a takuzu.Annotation) {
+	if err := s.game.ToggleAnnotation(s.line, s.col, a); err != nil {
+		s.message = "Cannot annotate this cell: " + err.Error() + "."
+	}
+}
+
+// annotationText returns a short representation of an annotation
+func annotationText(a takuzu.Annotation) string {
+	switch {
+	case a&takuzu.MarkZero != 0 && a&takuzu.MarkOne == 0:
+		return "0?"
+	case a&takuzu.MarkOne != 0 && a&takuzu.MarkZero == 0:
+		return "1?"
+	case a&takuzu.NotZero != 0 && a&takuzu.NotOne == 0:
+		return "¬0"
+	case a&takuzu.NotOne != 0 && a&takuzu.NotZero == 0:
+		return "¬1"
+	}
+	return " ."
+}
+
+func (s *playSession) undoMove() {
+	m, err := s.game.Undo()
+	if err != nil {
+		s.message = "Nothing to undo."
+		return
+	}
+	s.line, s.col = m.Line, m.Col
+}
+
+func (s *playSession) redoMove() {
+	m, err := s.game.Redo()
+	if err != nil {
+		s.message = "Nothing to redo."
+		return
+	}
+	s.line, s.col = m.Line, m.Col
+}
+
+// showHint displays a hint; the hint is more detailed each time the
+// player asks for it, until the board is modified.
+func (s *playSession) showHint() {
+	if s.hint != nil && s.hintLevel < takuzu.HintCell {
+		s.hintLevel++
+	} else if s.hint == nil {
+		h, err := s.game.Hint(playHintTimeout)
+		if err != nil {
+			s.message = "No hint available: " + err.Error()
+			return
+		}
+		if h == nil {
+			s.message = "No hint available."
+			return
+		}
+		s.hint, s.hintLevel = h, takuzu.HintRegion
+	}
+	if s.hintLevel == takuzu.HintCell {
+		s.line, s.col = s.hint.Line, s.hint.Col
+	}
+	s.message = "Hint: " + s.hint.Message(s.hintLevel)
+}
+
+// check compares the board with the solution and highlights the wrong
+// cells
+func (s *playSession) check() {
+	res, err := s.game.Check(playHintTimeout)
+	switch {
+	case err != nil:
+		s.message = "Cannot check the board: " + err.Error()
+	case len(res.WrongCells) > 0:
+		s.wrong = res.WrongCells
+		s.message = fmt.Sprintf("%d cell(s) do not match the solution.", len(res.WrongCells))
+	case !res.Consistent:
+		s.message = "The board cannot be completed."
+	case !res.Unique:
+		s.message = "The board can still be completed (the puzzle has several solutions)."
+	default:
+		s.message = "No mistake so far."
+	}
+}
+
+// handleKey processes a key; it returns false if the game should stop
+func (s *playSession) handleKey(k int) bool {
+	s.message = ""
+	n := s.game.State.Size
+	moves := len(s.game.Moves)
+	switch k {
+	case keyUp, 'k':
+		s.line = (s.line + n - 1) % n
+	case keyDown, 'j':
+		s.line = (s.line + 1) % n
+	case keyLeft, 'h':
+		s.col = (s.col + n - 1) % n
+	case keyRight, 'l':
+		s.col = (s.col + 1) % n
+	case ' ', '\r':
+		// Cycle through empty, 0 and 1
+		s.set((s.game.Value(s.line, s.col)+2)%3 - 1)
+	case '0', '1':
+		s.set(k - '0')
+	case 'x', '.', 127:
+		s.set(-1)
+	case 'm':
+		// Cycle through no mark, tentative 0 and tentative 1
+		switch s.game.Annotation(s.line, s.col) & (takuzu.MarkZero | takuzu.MarkOne) {
+		case 0:
+			s.annotate(takuzu.MarkZero)
+		case takuzu.MarkZero:
+			s.annotate(takuzu.MarkZero | takuzu.MarkOne)
+		default:
+			s.annotate(takuzu.MarkOne)
+		}
+	case 'n':
+		// Cycle through no exclusion, "not 0" and "not 1"
+		switch s.game.Annotation(s.line, s.col) & (takuzu.NotZero | takuzu.NotOne) {
+		case 0:
+			s.annotate(takuzu.NotZero)
+		case takuzu.NotZero:
+			s.annotate(takuzu.NotZero | takuzu.NotOne)
+		default:
+			s.annotate(takuzu.NotOne)
+		}
+	case 'a':
+		if n, err := s.game.AutoCandidates(); err != nil {
+			s.message = "Fix the errors first: " + err.Error()
+		} else {
+			s.message = fmt.Sprintf("%d cell(s) annotated.", n)
+		}
+	case 'c':
+		s.annotate(s.game.Annotation(s.line, s.col))
+	case 'u':
+		s.undoMove()
+	case 'r':
+		s.redoMove()
+	case '?':
+		s.showHint()
+	case 'v':
+		s.check()
+	case 's':
+		if err := s.save(); err != nil {
+			s.message = "Could not save the game: " + err.Error()
+		} else {
+			s.message = "Game saved to " + s.saveFile + "."
+		}
+	case 'q', 3: // 3 is Ctrl-C
+		return false
+	}
+
+	if len(s.game.Moves) != moves {
+		// The board has changed
+		s.hint = nil
+		s.wrong = nil
+	}
+
+	if s.game.Completed() {
+		s.completed = true
+		return false
+	}
+	return true
+}
+
+// draw renders the game
+func (s *playSession) draw() {
+	var buf bytes.Buffer
+	buf.WriteString(ansiClear)
+
+	bad := make(map[takuzu.Position]bool)
+	state := s.game.State
+	for _, p := range append(state.ErrorCells(), s.wrong...) {
+		bad[p] = true
+	}
+
+	buf.WriteString(fmt.Sprintf("Takuzu %dx%[1]d   time %v   moves %d\r\n\r\n",
+		state.Size, s.playTime().Round(time.Second), len(s.game.Moves)))
+	for l := range state.Board {
+		buf.WriteString("  ")
+		for c, cell := range state.Board[l] {
+			txt := annotationText(s.game.Annotation(l, c)) + " "
+			if cell.Defined {
+				txt = " " + fmt.Sprint(cell.Value) + " "
+			}
+			style := ansiDim
+			if cell.Defined {
+				style = ansiBlue
+				if s.game.Given(l, c) {
+					style = ansiBold
+				}
+			}
+			if bad[takuzu.Position{Line: l, Col: c}] {
+				style += ansiRed
+			}
+			if l == s.line && c == s.col {
+				style += ansiReverse
+			}
+			buf.WriteString(style + txt + ansiReset)
+		}
+		buf.WriteString("\r\n")
+	}
+	buf.WriteString("\r\n" + playHelp + "\r\n")
+	if s.message != "" {
+		buf.WriteString("\r\n" + s.message + "\r\n")
+	}
+	os.Stdout.Write(buf.Bytes())
+}
+
+// stty runs stty(1) on the terminal and returns its output
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// readKeys reads the keyboard input and sends the keys to the channel
+func readKeys(keys chan<- int) {
+	buf := make([]byte, 16)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		for i := 0; i < n; i++ {
+			// Arrow keys: ESC [ A-D
+			if buf[i] == 0x1b && i+2 < n && buf[i+1] == '[' {
+				switch buf[i+2] {
+				case 'A':
+					keys <- keyUp
+				case 'B':
+					keys <- keyDown
+				case 'C':
+					keys <- keyRight
+				case 'D':
+					keys <- keyLeft
+				}
+				i += 2
+				continue
+			}
+			keys <- int(buf[i])
+		}
+	}
+}
+
+// run runs the game until it is completed or the player quits
+func (s *playSession) run() error {
+	saved, err := stty("-g")
+	if err != nil {
+		return errors.Wrap(err, "cannot set up the terminal")
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return errors.Wrap(err, "cannot set up the terminal")
+	}
+	fmt.Print(ansiHideCur)
+	defer func() {
+		fmt.Print(ansiShowCur)
+		stty(saved)
+	}()
+
+	s.start = time.Now()
+	keys := make(chan int)
+	go readKeys(keys)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	s.draw()
+	for {
+		select {
+		case k, ok := <-keys:
+			if !ok || !s.handleKey(k) {
+				s.draw()
+				fmt.Print("\r\n")
+				return nil
+			}
+		case <-ticker.C:
+		}
+		s.draw()
+	}
+}
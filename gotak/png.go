@@ -0,0 +1,43 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package main
+
+import (
+	"image/png"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/McKael/takuzu"
+)
+
+func parseImageTheme(name string) (takuzu.ImageTheme, error) {
+	switch name {
+	case "classic", "":
+		return takuzu.ThemeClassic, nil
+	case "discs":
+		return takuzu.ThemeDiscs, nil
+	}
+	return takuzu.ThemeClassic, errors.Errorf("unknown image theme '%s'", name)
+}
+
+func tak2png(tak *takuzu.Takuzu, pngFileName string, opts takuzu.ImageOptions) error {
+
+	if pngFileName == "" {
+		return errors.New("no PNG file name")
+	}
+
+	f, err := os.Create(pngFileName)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(f, tak.Image(opts)); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
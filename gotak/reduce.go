@@ -0,0 +1,59 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/spf13/pflag"
+)
+
+var reduceCommand = &command{
+	name:        "reduce",
+	args:        "[BOARD]",
+	description: "Try to reduce the number of digits of a board",
+	exitCodes: []string{
+		"0  The board was reduced",
+		"1  The board is invalid or could not be reduced",
+		"4  Timeout",
+	},
+	run: runReduce,
+}
+
+func runReduce(fs *pflag.FlagSet, args []string) int {
+	cf := addCommonFlags(fs)
+	bf := addTimeoutFlags(fs)
+	board := fs.String("board", "", "Board string (\"-\" to read it from the standard input)")
+	simple := fs.Bool("simple", false, "Keep a board that can be solved with trivial methods")
+	out := fs.Bool("out", false, "Send board string to output")
+
+	if code := parseFlags(fs, cf, args); code >= 0 {
+		return code
+	}
+
+	rep := newReport("reduce")
+
+	tak, err := loadBoard(fs, *board)
+	if err != nil {
+		return rep.done("error", err, loadBoardExitCode(err))
+	}
+	rep.setBoard(tak)
+
+	printBoard(tak, nil)
+
+	bf.logSettings()
+	if tak, err = tak.ReduceBoard(*simple, "0", *bf.buildBoardTimeout, *bf.reduceBoardTimeout); err != nil {
+		if isTimeout(err) {
+			return rep.done("timeout", err, exitTimeout)
+		}
+		return rep.done("error", err, exitError)
+	}
+
+	printBoard(tak, nil)
+
+	if *out {
+		printString(tak)
+	}
+	rep.Result = tak.ToString()
+	return rep.done("reduced", nil, exitOK)
+}
@@ -0,0 +1,175 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package main
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/McKael/takuzu"
+)
+
+var renderCommand = &command{
+	name:        "render",
+	args:        "[BOARD]",
+	description: "Write a board to PDF, PNG, LaTeX or DIMACS files, or build a PDF booklet",
+	exitCodes: []string{
+		"0  The files were written",
+		"1  The board is invalid or could not be solved, or a file could not be written",
+	},
+	run: runRender,
+}
+
+func runRender(fs *pflag.FlagSet, args []string) int {
+	cf := addCommonFlags(fs)
+	board := fs.String("board", "", "Board string (\"-\" to read it from the standard input)")
+	out := fs.Bool("out", false, "Send board string to output")
+	resolveTimeout := fs.Duration("x-timeout", 0, "[Advanced] Resolution timeout")
+
+	pdfFileName := fs.String("to-pdf", "", "PDF output file name")
+	pdfPageSize := fs.String("pdf-page-size", "A4", "PDF page size (A3, A4, A5, Letter, Legal)")
+	pdfLandscape := fs.Bool("pdf-landscape", false, "Use landscape orientation for PDF pages")
+	pdfBlockSize := fs.Uint("pdf-block", 0, "Draw thicker PDF grid lines every N cells")
+	pdfShadeGivens := fs.Bool("pdf-shade-givens", false, "Shade the given cells in PDF files")
+
+	bookletFileName := fs.String("booklet", "", "Build a PDF booklet from a collection file (use with --to-pdf)")
+	bookletTitle := fs.String("booklet-title", "Takuzu", "PDF booklet title")
+	bookletColumns := fs.Uint("booklet-columns", 2, "Number of puzzle columns per booklet page")
+	bookletRows := fs.Uint("booklet-rows", 3, "Number of puzzle rows per booklet page")
+	bookletAnswerColumns := fs.Uint("booklet-answer-columns", 4, "Number of solution columns per answer page")
+
+	pngFileName := fs.String("to-png", "", "PNG output file name")
+	pngCellSize := fs.Uint("png-cell-size", takuzu.DefaultImageCellSize, "PNG cell size in pixels")
+	pngTheme := fs.String("png-theme", "classic", "PNG theme (classic, discs)")
+	pngSolution := fs.Bool("png-solution", false, "Render the solution in the PNG file")
+
+	texFileName := fs.String("to-tex", "", "LaTeX/TikZ output file name")
+	texStandalone := fs.Bool("tex-standalone", false, "Write a standalone LaTeX document")
+	texSolution := fs.Bool("tex-solution", false, "Show the solution in the TikZ picture")
+	texHighlight := fs.StringSlice("tex-highlight", nil, "Cells to highlight in the TikZ picture (line:col,...)")
+
+	dimacsFileName := fs.String("to-dimacs", "", "DIMACS CNF output file name, for external SAT solvers")
+
+	if code := parseFlags(fs, cf, args); code >= 0 {
+		return code
+	}
+
+	rep := newReport("render")
+
+	pdfOpts := pdfOptions{
+		pageSize:    *pdfPageSize,
+		landscape:   *pdfLandscape,
+		blockSize:   int(*pdfBlockSize),
+		shadeGivens: *pdfShadeGivens,
+		cellSize:    8,
+	}
+
+	if *bookletFileName != "" {
+		if *pdfFileName == "" {
+			return rep.done("error", errors.New("--booklet requires --to-pdf"), exitUsage)
+		}
+		timeout := *resolveTimeout
+		if timeout == 0 {
+			timeout = 5 * time.Minute
+		}
+		puzzles, err := loadBooklet(*bookletFileName, timeout)
+		if err != nil {
+			return rep.done("error", err, exitError)
+		}
+		opts := bookletOptions{
+			title:         *bookletTitle,
+			columns:       int(*bookletColumns),
+			rows:          int(*bookletRows),
+			answerColumns: int(*bookletAnswerColumns),
+			pdfOptions:    pdfOpts,
+		}
+		if err := tak2pdfBooklet(puzzles, *pdfFileName, opts); err != nil {
+			return rep.done("error", err, exitError)
+		}
+		rep.Files = []string{*pdfFileName}
+		return rep.done("rendered", nil, exitOK)
+	}
+
+	if *pdfFileName == "" && *pngFileName == "" && *texFileName == "" && *dimacsFileName == "" {
+		return rep.done("error", errors.New("no output file"), exitUsage)
+	}
+
+	tak, err := loadBoard(fs, *board)
+	if err != nil {
+		return rep.done("error", err, loadBoardExitCode(err))
+	}
+	rep.setBoard(tak)
+
+	var solution *takuzu.Takuzu
+	if *pngSolution || *texSolution {
+		res, err := tak.Clone().TrySolveRecurse(nil, *resolveTimeout)
+		if err != nil || res == nil {
+			if err == nil {
+				err = errors.New("could not solve the takuzu")
+			} else {
+				err = errors.Wrap(err, "could not solve the takuzu")
+			}
+			return rep.done("unsolvable", err, exitError)
+		}
+		solution = res
+	}
+
+	if *pdfFileName != "" {
+		if err := tak2pdf(tak, *pdfFileName, pdfOpts); err != nil {
+			return rep.done("error", err, exitError)
+		}
+		rep.Files = append(rep.Files, *pdfFileName)
+	}
+
+	if *pngFileName != "" {
+		theme, err := parseImageTheme(*pngTheme)
+		if err != nil {
+			return rep.done("error", err, exitUsage)
+		}
+		opts := takuzu.ImageOptions{
+			CellSize: int(*pngCellSize),
+			Theme:    theme,
+		}
+		board := tak
+		if *pngSolution {
+			board = solution
+			opts.Puzzle = tak
+		}
+		if err := tak2png(board, *pngFileName, opts); err != nil {
+			return rep.done("error", err, exitError)
+		}
+		rep.Files = append(rep.Files, *pngFileName)
+	}
+
+	if *texFileName != "" {
+		highlight, err := parsePositions(*texHighlight)
+		if err != nil {
+			return rep.done("error", err, exitUsage)
+		}
+		opts := takuzu.TikZOptions{
+			Standalone: *texStandalone,
+			Solution:   solution,
+			Highlight:  highlight,
+		}
+		if err := tak2tex(tak, *texFileName, opts); err != nil {
+			return rep.done("error", err, exitError)
+		}
+		rep.Files = append(rep.Files, *texFileName)
+	}
+
+	if *dimacsFileName != "" {
+		if err := tak2dimacs(tak, *dimacsFileName); err != nil {
+			return rep.done("error", err, exitError)
+		}
+		rep.Files = append(rep.Files, *dimacsFileName)
+	}
+
+	if *out {
+		printString(tak)
+	}
+	return rep.done("rendered", nil, exitOK)
+}
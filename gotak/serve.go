@@ -0,0 +1,472 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package main
+
+// This file contains the HTTP JSON API server.
+// The handlers are created by newServer and do not depend on global state
+// (except for the verbosity), so that they can be used with net/http/httptest.
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/McKael/takuzu"
+)
+
+var serveCommand = &command{
+	name:        "serve",
+	args:        "",
+	description: "Run an HTTP JSON API server",
+	exitCodes: []string{
+		"0  The server was stopped",
+		"1  The server could not be started",
+	},
+	run: runServe,
+}
+
+// serverConfig contains the limits of the API server
+type serverConfig struct {
+	maxBodySize    int64         // Maximum request body size, in bytes
+	maxSize        int           // Maximum board size
+	maxSolutions   int           // Maximum number of solutions returned
+	defaultTimeout time.Duration // Request timeout when not requested
+	maxTimeout     time.Duration // Maximum request timeout
+
+	sem chan struct{} // Limits the number of concurrent requests
+}
+
+// apiRequest is the JSON body of the API requests.
+// Not all the fields are used by all the endpoints.
+type apiRequest struct {
+	Board      string  `json:"board"`
+	Puzzle     string  `json:"puzzle"`
+	Size       int     `json:"size"`
+	Difficulty string  `json:"difficulty"`
+	Seed       int64   `json:"seed"`
+	Timeout    float64 `json:"timeout"` // Seconds
+	Limit      int     `json:"limit"`   // Maximum number of solutions
+	Level      int     `json:"level"`   // Hint level
+}
+
+// apiHandler processes an API request and fills the report.
+// It returns the HTTP status code of the response.
+type apiHandler func(ctx context.Context, req *apiRequest, rep *report) int
+
+func runServe(fs *pflag.FlagSet, args []string) int {
+	cf := addCommonFlags(fs)
+	listen := fs.String("listen", "localhost:8080", "Listen address")
+	maxBody := fs.Int64("max-body", 64*1024, "Maximum request body size in bytes")
+	maxSize := fs.Uint("max-size", 20, "Maximum board size")
+	maxSolutions := fs.Uint("max-solutions", 100, "Maximum number of solutions returned by the solve endpoint")
+	maxConcurrent := fs.Uint("max-concurrent", uint(runtime.NumCPU()), "Maximum number of requests processed concurrently")
+	timeout := fs.Duration("x-timeout", 30*time.Second, "[Advanced] Default request timeout")
+	maxTimeout := fs.Duration("x-max-timeout", 5*time.Minute, "[Advanced] Maximum request timeout")
+
+	if code := parseFlags(fs, cf, args); code >= 0 {
+		return code
+	}
+	if *maxConcurrent == 0 {
+		*maxConcurrent = 1
+	}
+
+	cfg := &serverConfig{
+		maxBodySize:    *maxBody,
+		maxSize:        int(*maxSize),
+		maxSolutions:   int(*maxSolutions),
+		defaultTimeout: *timeout,
+		maxTimeout:     *maxTimeout,
+		sem:            make(chan struct{}, *maxConcurrent),
+	}
+
+	srv := &http.Server{
+		Addr:              *listen,
+		Handler:           newServer(cfg),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// Stop the server on interrupt; the requests in progress are canceled.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	srv.BaseContext = func(_ net.Listener) context.Context { return ctx }
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+	log.Printf("Listening on %s", *listen)
+
+	select {
+	case err := <-errc:
+		log.Println(err)
+		return exitError
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Println(err)
+		return exitError
+	}
+	return exitOK
+}
+
+// newServer returns the HTTP handler of the API
+func newServer(cfg *serverConfig) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/generate", cfg.handler("generate", cfg.generate))
+	mux.Handle("/solve", cfg.handler("solve", cfg.solve))
+	mux.Handle("/validate", cfg.handler("validate", cfg.validate))
+	mux.Handle("/hint", cfg.handler("hint", cfg.hint))
+	mux.Handle("/grade", cfg.handler("grade", cfg.grade))
+	return mux
+}
+
+// handler wraps an API handler: it checks the request, applies the limits
+// and writes the JSON report.  The request context is canceled when the
+// client goes away or when the request timeout expires.
+func (cfg *serverConfig) handler(name string, process apiHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rep := newReport(name)
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			rep.finish("error", errors.New("method not allowed"))
+			writeReport(w, rep, http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req apiRequest
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, cfg.maxBodySize))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			code := http.StatusBadRequest
+			var mbe *http.MaxBytesError
+			if errors.As(err, &mbe) {
+				code = http.StatusRequestEntityTooLarge
+			}
+			rep.finish("error", errors.Wrap(err, "invalid request"))
+			writeReport(w, rep, code)
+			return
+		}
+
+		timeout := time.Duration(req.Timeout * float64(time.Second))
+		if timeout <= 0 {
+			timeout = cfg.defaultTimeout
+		}
+		if cfg.maxTimeout > 0 && timeout > cfg.maxTimeout {
+			timeout = cfg.maxTimeout
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		select {
+		case cfg.sem <- struct{}{}:
+			defer func() { <-cfg.sem }()
+		case <-ctx.Done():
+			rep.finish("busy", errors.New("the server is busy"))
+			writeReport(w, rep, http.StatusServiceUnavailable)
+			return
+		}
+
+		code := process(ctx, &req, rep)
+		if verbosity > 0 {
+			log.Printf("%s %s: %s (%.3fs)", r.Method, r.URL.Path, rep.Status, rep.Elapsed)
+		}
+		if r.Context().Err() != nil {
+			// The client is gone
+			return
+		}
+		writeReport(w, rep, code)
+	})
+}
+
+// writeReport writes the JSON report with the HTTP status code
+func writeReport(w http.ResponseWriter, rep *report, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(rep); err != nil {
+		log.Println(err)
+	}
+}
+
+// parseBoard parses a board string of a request and checks its size
+func (cfg *serverConfig) parseBoard(s string) (*takuzu.Takuzu, error) {
+	if s == "" {
+		return nil, errors.New("no board")
+	}
+	tak, err := takuzu.NewFromString(s)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.maxSize > 0 && tak.Size > cfg.maxSize {
+		return nil, errors.Errorf("board size is too large (maximum %d)", cfg.maxSize)
+	}
+	return tak, nil
+}
+
+// isCanceled returns true if the error was caused by the request context
+func isCanceled(err error) bool {
+	switch errors.Cause(err) {
+	case context.Canceled, context.DeadlineExceeded:
+		return true
+	}
+	return isTimeout(err)
+}
+
+// generate builds a new puzzle
+func (cfg *serverConfig) generate(ctx context.Context, req *apiRequest, rep *report) int {
+	if req.Size == 0 {
+		rep.finish("error", errors.New("no board size"))
+		return http.StatusBadRequest
+	}
+	if cfg.maxSize > 0 && req.Size > cfg.maxSize {
+		rep.finish("error", errors.Errorf("board size is too large (maximum %d)", cfg.maxSize))
+		return http.StatusBadRequest
+	}
+	var wanted takuzu.Difficulty
+	if req.Difficulty != "" {
+		d, err := takuzu.ParseDifficulty(req.Difficulty)
+		if err != nil {
+			rep.finish("error", err)
+			return http.StatusBadRequest
+		}
+		wanted = d
+	}
+
+	// Puzzles are built until the difficulty matches; the seed is
+	// incremented for each attempt so that the result is reproducible.
+	for attempt := int64(0); ; attempt++ {
+		opts := takuzu.BuildOptions{
+			Size:     req.Size,
+			Simple:   wanted == takuzu.DifficultyEasy,
+			MinRatio: takuzu.DefaultMinRatio,
+			MaxRatio: takuzu.DefaultMaxRatio,
+			Context:  ctx,
+		}
+		if req.Seed != 0 {
+			opts.Seed = req.Seed + attempt
+		}
+		tak, err := takuzu.NewRandomTakuzuWithOptions(opts)
+		if err != nil {
+			if isCanceled(err) {
+				rep.finish("timeout", err)
+				return http.StatusOK
+			}
+			rep.finish("error", err)
+			return http.StatusBadRequest
+		}
+		d, err := tak.GradeWithOptions(takuzu.SolveOptions{Context: ctx})
+		if err != nil {
+			if isCanceled(err) {
+				rep.finish("timeout", err)
+				return http.StatusOK
+			}
+			continue
+		}
+		if wanted != takuzu.DifficultyUnknown && d != wanted {
+			continue
+		}
+		rep.Result = tak.ToString()
+		rep.Stats = newBoardStats(tak)
+		rep.Difficulty = d.String()
+		rep.finish("generated", nil)
+		return http.StatusOK
+	}
+}
+
+// solve solves a board; at most limit solutions are returned
+func (cfg *serverConfig) solve(ctx context.Context, req *apiRequest, rep *report) int {
+	tak, err := cfg.parseBoard(req.Board)
+	if err != nil {
+		rep.finish("error", err)
+		return http.StatusBadRequest
+	}
+	rep.setBoard(tak)
+
+	limit := req.Limit
+	if limit <= 0 || (cfg.maxSolutions > 0 && limit > cfg.maxSolutions) {
+		limit = cfg.maxSolutions
+	}
+
+	allSol := &[]takuzu.Takuzu{}
+	_, err = tak.Clone().TrySolveWithOptions(allSol,
+		takuzu.SolveOptions{MaxSolutions: limit, Context: ctx})
+	rep.setSolutions(*allSol)
+	switch {
+	case isCanceled(err):
+		rep.finish("timeout", err)
+	case len(*allSol) == 1:
+		rep.finish("solved", nil)
+	case len(*allSol) > 1:
+		rep.finish("multiple", nil)
+	default:
+		if _, verr := tak.Validate(); verr != nil {
+			rep.finish("invalid", verr)
+			break
+		}
+		rep.finish("unsolvable", err)
+	}
+	return http.StatusOK
+}
+
+// validate checks a board against the rules and, if the puzzle is
+// provided, against the solution of the puzzle
+func (cfg *serverConfig) validate(ctx context.Context, req *apiRequest, rep *report) int {
+	tak, err := cfg.parseBoard(req.Board)
+	if err != nil {
+		rep.finish("error", err)
+		return http.StatusBadRequest
+	}
+	rep.setBoard(tak)
+
+	full, err := tak.Validate()
+	if err != nil {
+		rep.finish("invalid", err)
+		return http.StatusOK
+	}
+
+	if req.Puzzle != "" {
+		puzzle, err := cfg.parseBoard(req.Puzzle)
+		if err != nil {
+			rep.finish("error", errors.Wrap(err, "invalid puzzle"))
+			return http.StatusBadRequest
+		}
+		res, err := takuzu.CheckStateWithOptions(*puzzle, *tak, takuzu.SolveOptions{Context: ctx})
+		if err != nil {
+			switch {
+			case isCanceled(err):
+				rep.finish("timeout", err)
+			case errors.Cause(err) == takuzu.ErrNoSolution:
+				rep.finish("no solution", err)
+			default:
+				rep.finish("invalid", err)
+			}
+			return http.StatusOK
+		}
+		rep.Check = &checkReport{
+			Unique:     res.Unique,
+			Consistent: res.Consistent,
+			WrongCells: res.WrongCells,
+		}
+		if !res.Consistent {
+			rep.finish("inconsistent", nil)
+			return http.StatusOK
+		}
+	}
+
+	if !full {
+		rep.finish("incomplete", nil)
+		return http.StatusOK
+	}
+	rep.finish("complete", nil)
+	return http.StatusOK
+}
+
+// hint gives a hint about the board; if the puzzle is provided, mistakes
+// are reported first
+func (cfg *serverConfig) hint(ctx context.Context, req *apiRequest, rep *report) int {
+	level := takuzu.HintLevel(req.Level)
+	if req.Level == 0 {
+		level = takuzu.HintCell
+	}
+	if level < takuzu.HintRegion || level > takuzu.HintCell {
+		rep.finish("error", errors.New("invalid hint level"))
+		return http.StatusBadRequest
+	}
+
+	tak, err := cfg.parseBoard(req.Board)
+	if err != nil {
+		rep.finish("error", err)
+		return http.StatusBadRequest
+	}
+	rep.setBoard(tak)
+
+	var hint *takuzu.Hint
+	if req.Puzzle != "" {
+		puzzle, err := cfg.parseBoard(req.Puzzle)
+		if err != nil {
+			rep.finish("error", errors.Wrap(err, "invalid puzzle"))
+			return http.StatusBadRequest
+		}
+		if puzzle.Size != tak.Size {
+			rep.finish("error", errors.New("puzzle and board sizes do not match"))
+			return http.StatusBadRequest
+		}
+		if match, _, _ := takuzu.BoardsMatch(puzzle, tak, true); !match {
+			rep.finish("invalid", errors.New("the board doesn't match the puzzle"))
+			return http.StatusOK
+		}
+		game := takuzu.NewGame(*puzzle)
+		game.SetState(*tak)
+		if hint, err = game.HintWithOptions(takuzu.SolveOptions{Context: ctx}); err != nil {
+			if isCanceled(err) {
+				rep.finish("timeout", err)
+			} else {
+				rep.finish("invalid", err)
+			}
+			return http.StatusOK
+		}
+	}
+
+	if hint == nil {
+		if _, err := tak.Validate(); err != nil {
+			rep.finish("invalid", err)
+			return http.StatusOK
+		}
+		hint = tak.Hint()
+	}
+
+	if hint == nil {
+		rep.finish("none", nil)
+		return http.StatusOK
+	}
+	rep.Hint = newHintReport(hint, level)
+	if hint.Technique == takuzu.TechniqueMistake {
+		rep.finish("mistake", nil)
+	} else {
+		rep.finish("hint", nil)
+	}
+	return http.StatusOK
+}
+
+// grade evaluates the difficulty of a puzzle
+func (cfg *serverConfig) grade(ctx context.Context, req *apiRequest, rep *report) int {
+	tak, err := cfg.parseBoard(req.Board)
+	if err != nil {
+		rep.finish("error", err)
+		return http.StatusBadRequest
+	}
+	rep.setBoard(tak)
+
+	d, err := tak.GradeWithOptions(takuzu.SolveOptions{Context: ctx})
+	if err != nil {
+		switch {
+		case isCanceled(err):
+			rep.finish("timeout", err)
+		case errors.Cause(err) == takuzu.ErrNoSolution:
+			rep.finish("unsolvable", err)
+		case errors.Cause(err) == takuzu.ErrMultipleSolutions:
+			rep.finish("multiple", err)
+		default:
+			rep.finish("invalid", err)
+		}
+		return http.StatusOK
+	}
+	rep.Difficulty = d.String()
+	rep.finish("graded", nil)
+	return http.StatusOK
+}
@@ -0,0 +1,188 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	// A 6x6 puzzle with a unique solution
+	testPuzzle   = "..0.....0.............0.1....1.....1"
+	testSolution = "010110100101011010110100101001001011"
+	// The puzzle with a wrong cell (line 0, column 0)
+	testMistake = "1.0.....0.............0.1....1.....1"
+)
+
+// emptyBoard returns the string of an empty board; large empty boards
+// cannot be solved before a short request timeout.
+func emptyBoard(size int) string {
+	return strings.Repeat(".", size*size)
+}
+
+func newTestServer() *httptest.Server {
+	return httptest.NewServer(newServer(&serverConfig{
+		maxBodySize:    1024,
+		maxSize:        20,
+		maxSolutions:   10,
+		defaultTimeout: 10 * time.Second,
+		maxTimeout:     time.Minute,
+		sem:            make(chan struct{}, 2),
+	}))
+}
+
+// post sends a request to the server and decodes the report
+func post(t *testing.T, srv *httptest.Server, path, body string) (int, *report) {
+	t.Helper()
+	resp, err := http.Post(srv.URL+path, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("%s: unexpected content type %q", path, ct)
+	}
+	var rep report
+	if err := json.NewDecoder(resp.Body).Decode(&rep); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, &rep
+}
+
+func TestServeEndpoints(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+
+	tests := []struct {
+		path, body string
+		code       int
+		status     string
+	}{
+		{"/generate", `{"size":6,"seed":7}`, http.StatusOK, "generated"},
+		{"/generate", `{"size":6,"difficulty":"easy","seed":7}`, http.StatusOK, "generated"},
+		{"/generate", `{}`, http.StatusBadRequest, "error"},
+		{"/generate", `{"size":22}`, http.StatusBadRequest, "error"},
+		{"/generate", `{"size":5}`, http.StatusBadRequest, "error"},
+		{"/generate", `{"size":6,"difficulty":"nightmare"}`, http.StatusBadRequest, "error"},
+
+		{"/solve", `{"board":"` + testPuzzle + `"}`, http.StatusOK, "solved"},
+		{"/solve", `{"board":"1.0.............","limit":3}`, http.StatusOK, "multiple"},
+		{"/solve", `{"board":"111............."}`, http.StatusOK, "invalid"},
+		{"/solve", `{"board":"1.0.."}`, http.StatusBadRequest, "error"},
+		{"/solve", `{}`, http.StatusBadRequest, "error"},
+
+		{"/validate", `{"board":"` + testPuzzle + `"}`, http.StatusOK, "incomplete"},
+		{"/validate", `{"board":"` + testSolution + `"}`, http.StatusOK, "complete"},
+		{"/validate", `{"board":"111............."}`, http.StatusOK, "invalid"},
+		{"/validate", `{"board":"` + testSolution + `","puzzle":"` + testPuzzle + `"}`, http.StatusOK, "complete"},
+		{"/validate", `{"board":"` + testMistake + `","puzzle":"` + testPuzzle + `"}`, http.StatusOK, "inconsistent"},
+		{"/validate", `{"board":"` + emptyBoard(12) + `","puzzle":"` + emptyBoard(12) + `"}`, http.StatusOK, "incomplete"},
+		{"/validate", `{"board":"` + emptyBoard(20) + `","puzzle":"` + emptyBoard(20) + `","timeout":0.1}`, http.StatusOK, "timeout"},
+
+		{"/hint", `{"board":"` + testPuzzle + `"}`, http.StatusOK, "hint"},
+		{"/hint", `{"board":"` + testMistake + `","puzzle":"` + testPuzzle + `"}`, http.StatusOK, "mistake"},
+		{"/hint", `{"board":"` + testSolution + `"}`, http.StatusOK, "none"},
+		{"/hint", `{"board":"` + testPuzzle + `","level":9}`, http.StatusBadRequest, "error"},
+		{"/hint", `{"board":"` + testPuzzle + `","puzzle":"1.0............."}`, http.StatusBadRequest, "error"},
+		{"/hint", `{"board":"` + emptyBoard(12) + `","puzzle":"` + emptyBoard(12) + `"}`, http.StatusOK, "invalid"},
+		{"/hint", `{"board":"` + emptyBoard(20) + `","puzzle":"` + emptyBoard(20) + `","timeout":0.1}`, http.StatusOK, "timeout"},
+
+		{"/grade", `{"board":"` + testPuzzle + `"}`, http.StatusOK, "graded"},
+		{"/grade", `{"board":"1.0............."}`, http.StatusOK, "multiple"},
+		{"/grade", `{"board":"0.....01.1.1.1.."}`, http.StatusOK, "unsolvable"},
+		{"/grade", `{"board":"111............."}`, http.StatusOK, "invalid"},
+		{"/grade", `{"board":"` + emptyBoard(12) + `"}`, http.StatusOK, "multiple"},
+		{"/grade", `{"board":"` + emptyBoard(20) + `","timeout":0.1}`, http.StatusOK, "timeout"},
+	}
+	for _, tt := range tests {
+		code, rep := post(t, srv, tt.path, tt.body)
+		if code != tt.code || rep.Status != tt.status {
+			t.Errorf("%s %s: got %d %q (%s), want %d %q",
+				tt.path, tt.body, code, rep.Status, rep.Error, tt.code, tt.status)
+		}
+	}
+}
+
+func TestServeResults(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+
+	_, rep := post(t, srv, "/solve", `{"board":"`+testPuzzle+`"}`)
+	if len(rep.Solutions) != 1 || rep.Solutions[0] != testSolution {
+		t.Errorf("solve: unexpected solutions %v", rep.Solutions)
+	}
+
+	_, rep = post(t, srv, "/solve", `{"board":"1.0.............","limit":3}`)
+	if len(rep.Solutions) != 3 {
+		t.Errorf("solve: got %d solutions, want 3", len(rep.Solutions))
+	}
+
+	// The limit cannot be larger than the maximum number of solutions
+	_, rep = post(t, srv, "/solve", `{"board":"`+emptyBoard(8)+`","limit":50}`)
+	if len(rep.Solutions) != 10 {
+		t.Errorf("solve: got %d solutions, want 10", len(rep.Solutions))
+	}
+
+	// The same seed builds the same puzzle
+	_, rep1 := post(t, srv, "/generate", `{"size":6,"seed":7}`)
+	_, rep2 := post(t, srv, "/generate", `{"size":6,"seed":7}`)
+	if rep1.Result == "" || rep1.Result != rep2.Result {
+		t.Errorf("generate: %q and %q differ", rep1.Result, rep2.Result)
+	}
+
+	_, rep = post(t, srv, "/validate", `{"board":"`+testMistake+`","puzzle":"`+testPuzzle+`"}`)
+	if rep.Check == nil || !rep.Check.Unique || len(rep.Check.WrongCells) != 1 ||
+		rep.Check.WrongCells[0].Line != 0 || rep.Check.WrongCells[0].Col != 0 {
+		t.Errorf("validate: unexpected check %+v", rep.Check)
+	}
+
+	_, rep = post(t, srv, "/hint", `{"board":"`+testMistake+`","puzzle":"`+testPuzzle+`"}`)
+	if rep.Hint == nil || rep.Hint.Line == nil || *rep.Hint.Line != 0 || *rep.Hint.Col != 0 {
+		t.Errorf("hint: unexpected hint %+v", rep.Hint)
+	}
+
+	// Only the region is revealed at level 1
+	_, rep = post(t, srv, "/hint", `{"board":"`+testPuzzle+`","level":1}`)
+	if rep.Hint == nil || rep.Hint.Technique != "" || rep.Hint.Line != nil {
+		t.Errorf("hint: unexpected hint %+v", rep.Hint)
+	}
+
+	_, rep = post(t, srv, "/grade", `{"board":"`+testPuzzle+`"}`)
+	if rep.Difficulty == "" {
+		t.Error("grade: no difficulty")
+	}
+}
+
+func TestServeRequestErrors(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+
+	for _, path := range []string{"/generate", "/solve", "/validate", "/hint", "/grade"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != http.MethodPost {
+			t.Errorf("GET %s: got %d", path, resp.StatusCode)
+		}
+
+		if code, _ := post(t, srv, path, `{"unknown":1}`); code != http.StatusBadRequest {
+			t.Errorf("%s: unknown field: got %d", path, code)
+		}
+		if code, _ := post(t, srv, path, `{"board":`); code != http.StatusBadRequest {
+			t.Errorf("%s: bad JSON: got %d", path, code)
+		}
+		body := `{"board":"` + strings.Repeat(".", 2048) + `"}`
+		if code, _ := post(t, srv, path, body); code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: large body: got %d", path, code)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/McKael/takuzu"
+)
+
+var solveCommand = &command{
+	name:        "solve",
+	args:        "[BOARD]",
+	description: "Solve a takuzu board",
+	exitCodes: []string{
+		"0  The board was solved (with a single solution if --all is used)",
+		"1  The board is invalid",
+		"2  No solution was found (or the board could not be completed with --simple)",
+		"3  Several solutions were found (with --all)",
+		"4  Timeout",
+	},
+	run: runSolve,
+}
+
+func runSolve(fs *pflag.FlagSet, args []string) int {
+	cf := addCommonFlags(fs)
+	board := fs.String("board", "", "Board string (\"-\" to read it from the standard input)")
+	simple := fs.Bool("simple", false, "Only look for trivial solutions")
+	propagate := fs.Bool("propagate", false, "Use the line propagation engine instead of the trivial methods")
+	heuristic := fs.String("x-heuristic", "default", "[Advanced] Cell selection heuristic (default, first, constrained, neighbours, probe)")
+	sat := fs.Bool("x-sat", false, "[Advanced] Use the SAT solver backend")
+	assemble := fs.Bool("x-assemble", false, "[Advanced] Use the row-pattern assembly solver")
+	trail := fs.Bool("x-trail", false, "[Advanced] Use the trail-based backtracking solver")
+	all := fs.Bool("all", false, "Look for all possible solutions")
+	out := fs.Bool("out", false, "Send solution string to output")
+	resolveTimeout := fs.Duration("x-timeout", 0, "[Advanced] Resolution timeout")
+
+	if code := parseFlags(fs, cf, args); code >= 0 {
+		return code
+	}
+
+	rep := newReport("solve")
+
+	h, err := takuzu.ParseHeuristic(*heuristic)
+	if err != nil {
+		return rep.done("error", err, exitUsage)
+	}
+	if (*sat && *assemble) || (*sat && *trail) || (*assemble && *trail) {
+		return rep.done("error", errors.New("--x-sat, --x-assemble and --x-trail are mutually exclusive"), exitUsage)
+	}
+
+	tak, err := loadBoard(fs, *board)
+	if err != nil {
+		return rep.done("error", err, loadBoardExitCode(err))
+	}
+	rep.setBoard(tak)
+
+	// Keep a copy of the initial board, as the solver works in place
+	puzzle := tak.Clone()
+
+	printBoard(tak, nil)
+
+	if *simple {
+		solveSimple := tak.TrySolveTrivial
+		if *propagate {
+			solveSimple = tak.TrySolvePropagate
+		}
+		full, err := solveSimple()
+		if err != nil {
+			return rep.done("invalid", err, exitError)
+		}
+		rep.Result = tak.ToString()
+		if !full {
+			printBoard(tak, &puzzle)
+			if *out {
+				printString(tak)
+			}
+			if !jsonOutput() {
+				log.Println("The takuzu could not be completed using trivial methods.")
+			}
+			return rep.done("incomplete", nil, exitNoSolution)
+		}
+
+		if !jsonOutput() {
+			log.Println("The takuzu is correct and complete.")
+		}
+		printBoard(tak, &puzzle)
+		if *out {
+			printString(tak)
+		}
+		rep.setSolutions([]takuzu.Takuzu{*tak})
+		return rep.done("solved", nil, exitOK)
+	}
+
+	var allSol *[]takuzu.Takuzu
+	if *all {
+		allSol = &[]takuzu.Takuzu{}
+	}
+	opts := takuzu.SolveOptions{
+		Timeout:   *resolveTimeout,
+		Propagate: *propagate,
+		Heuristic: h,
+	}
+	solve := tak.TrySolveWithOptions
+	if *sat {
+		solve = tak.TrySolveSAT
+	} else if *assemble {
+		solve = tak.TrySolveAssemble
+	} else if *trail {
+		solve = tak.TrySolveTrail
+	}
+	res, err := solve(allSol, opts)
+	if err != nil && verbosity > 1 {
+		// The last trivial resolution failed
+		log.Println("Trivial resolution failed:", err)
+	}
+
+	if *all {
+		if !jsonOutput() {
+			log.Println(len(*allSol), "solution(s) found.")
+		}
+		for _, s := range *allSol {
+			if *out {
+				printString(&s)
+			} else {
+				printBoard(&s, &puzzle)
+			}
+		}
+		rep.setSolutions(*allSol)
+		switch {
+		case isTimeout(err):
+			return rep.done("timeout", err, exitTimeout)
+		case len(*allSol) > 1:
+			return rep.done("multiple", nil, exitMultiple)
+		case len(*allSol) == 1:
+			return rep.done("solved", nil, exitOK)
+		}
+		textln("No solution found.")
+		if _, verr := puzzle.Validate(); verr != nil {
+			return rep.done("invalid", verr, exitError)
+		}
+		return rep.done("unsolvable", nil, exitNoSolution)
+	}
+
+	if err != nil {
+		if isTimeout(err) {
+			return rep.done("timeout", err, exitTimeout)
+		}
+		if _, verr := puzzle.Validate(); verr != nil {
+			return rep.done("invalid", err, exitError)
+		}
+		return rep.done("unsolvable", err, exitNoSolution)
+	}
+	if res != nil {
+		printBoard(res, &puzzle)
+
+		if *out {
+			printString(res)
+		}
+		rep.setSolutions([]takuzu.Takuzu{*res})
+		return rep.done("solved", nil, exitOK)
+	}
+
+	textln("No solution found.")
+	return rep.done("unsolvable", nil, exitNoSolution)
+}
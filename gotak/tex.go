@@ -0,0 +1,52 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/McKael/takuzu"
+)
+
+// parsePositions parses a list of cell positions ("line:col")
+func parsePositions(list []string) ([]takuzu.Position, error) {
+	var positions []takuzu.Position
+	for _, s := range list {
+		f := strings.Split(s, ":")
+		if len(f) != 2 {
+			return nil, errors.Errorf("invalid cell position '%s'", s)
+		}
+		l, err1 := strconv.Atoi(f[0])
+		c, err2 := strconv.Atoi(f[1])
+		if err1 != nil || err2 != nil {
+			return nil, errors.Errorf("invalid cell position '%s'", s)
+		}
+		positions = append(positions, takuzu.Position{Line: l, Col: c})
+	}
+	return positions, nil
+}
+
+func tak2tex(tak *takuzu.Takuzu, texFileName string, opts takuzu.TikZOptions) error {
+
+	if texFileName == "" {
+		return errors.New("no TeX file name")
+	}
+
+	f, err := os.Create(texFileName)
+	if err != nil {
+		return err
+	}
+
+	if err := tak.WriteTikZ(f, opts); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
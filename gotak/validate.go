@@ -0,0 +1,104 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/McKael/takuzu"
+)
+
+var validateCommand = &command{
+	name:        "validate",
+	args:        "[BOARD]",
+	description: "Check a board against the takuzu rules",
+	exitCodes: []string{
+		"0  The board is valid and complete",
+		"1  The board is invalid, breaks the rules, or has mistakes (with --puzzle)",
+		"2  The board is valid but incomplete, or the puzzle has no solution",
+		"4  Timeout (with --puzzle)",
+	},
+	run: runValidate,
+}
+
+func runValidate(fs *pflag.FlagSet, args []string) int {
+	cf := addCommonFlags(fs)
+	board := fs.String("board", "", "Board string (\"-\" to read it from the standard input)")
+	puzzleString := fs.String("puzzle", "", "Initial puzzle string, used to look for mistakes")
+	resolveTimeout := fs.Duration("x-timeout", 0, "[Advanced] Resolution timeout")
+
+	if code := parseFlags(fs, cf, args); code >= 0 {
+		return code
+	}
+
+	rep := newReport("validate")
+
+	tak, err := loadBoard(fs, *board)
+	if err != nil {
+		return rep.done("error", err, loadBoardExitCode(err))
+	}
+	rep.setBoard(tak)
+
+	var puzzle *takuzu.Takuzu
+	if *puzzleString != "" {
+		if puzzle, err = takuzu.NewFromString(*puzzleString); err != nil {
+			return rep.done("error", errors.Wrap(err, "invalid puzzle"), exitUsage)
+		}
+	}
+
+	printBoard(tak, puzzle)
+
+	full, ruleErr := tak.Validate()
+	if ruleErr != nil {
+		textln("Invalid board:", ruleErr)
+		rep.Error = ruleErr.Error()
+	}
+
+	// The mistakes are reported even if the board breaks the rules
+	if puzzle != nil {
+		res, err := takuzu.CheckState(*puzzle, *tak, *resolveTimeout)
+		if err != nil {
+			textln("Could not check the board:", err)
+			if ruleErr == nil {
+				rep.Error = err.Error()
+			}
+			switch {
+			case ruleErr != nil:
+			case isTimeout(err):
+				return rep.done("timeout", nil, exitTimeout)
+			case errors.Cause(err) == takuzu.ErrNoSolution:
+				return rep.done("no solution", nil, exitNoSolution)
+			default:
+				return rep.done("invalid", nil, exitError)
+			}
+		} else {
+			rep.Check = &checkReport{
+				Unique:     res.Unique,
+				Consistent: res.Consistent,
+				WrongCells: res.WrongCells,
+			}
+			for _, p := range res.WrongCells {
+				textln(fmt.Sprintf("Line %d, column %d does not match the solution.", p.Line, p.Col))
+			}
+			if ruleErr == nil && !res.Consistent {
+				textln("The board cannot be completed.")
+				return rep.done("inconsistent", nil, exitError)
+			}
+		}
+	}
+	if ruleErr != nil {
+		return rep.done("invalid", nil, exitError)
+	}
+
+	if !full {
+		textln("The board is valid but incomplete.")
+		return rep.done("incomplete", nil, exitNoSolution)
+	}
+	textln("The board is valid and complete.")
+	return rep.done("complete", nil, exitOK)
+}
@@ -0,0 +1,86 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+// This file contains the functions used to grade a takuzu puzzle.
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Difficulty is the difficulty level of a puzzle
+type Difficulty int
+
+const (
+	// DifficultyUnknown is used when the puzzle could not be graded
+	DifficultyUnknown Difficulty = iota
+	// DifficultyEasy puzzles can be solved using trivial methods only
+	DifficultyEasy
+	// DifficultyHard puzzles require guesses
+	DifficultyHard
+)
+
+func (d Difficulty) String() string {
+	switch d {
+	case DifficultyEasy:
+		return "easy"
+	case DifficultyHard:
+		return "hard"
+	}
+	return "unknown"
+}
+
+// ParseDifficulty returns the difficulty level matching a name
+func ParseDifficulty(name string) (Difficulty, error) {
+	for d := DifficultyEasy; d <= DifficultyHard; d++ {
+		if d.String() == name {
+			return d, nil
+		}
+	}
+	return DifficultyUnknown, errors.Errorf("unknown difficulty '%s'", name)
+}
+
+// Grade returns the difficulty level of the puzzle.
+// An error is returned if the puzzle doesn't have exactly one solution.
+func (b Takuzu) Grade(timeout time.Duration) (Difficulty, error) {
+	return b.GradeWithOptions(SolveOptions{Timeout: timeout})
+}
+
+// GradeWithOptions returns the difficulty level of the puzzle, using the
+// given solver options.  The MaxSolutions option is ignored.
+func (b Takuzu) GradeWithOptions(opts SolveOptions) (Difficulty, error) {
+	d, _, err := b.GradeWithSolution(opts)
+	return d, err
+}
+
+// GradeWithSolution works like GradeWithOptions, and also returns the
+// solution of the puzzle.
+func (b Takuzu) GradeWithSolution(opts SolveOptions) (Difficulty, *Takuzu, error) {
+	if _, err := b.Validate(); err != nil {
+		return DifficultyUnknown, nil, errors.Wrap(err, "the takuzu looks wrong")
+	}
+
+	// Two solutions are enough to know the solution is not unique
+	opts.MaxSolutions = 2
+	allSol := &[]Takuzu{}
+	_, err := b.Clone().TrySolveWithOptions(allSol, opts)
+	if err != nil && isAbort(err) {
+		return DifficultyUnknown, nil, err
+	}
+	switch n := len(*allSol); {
+	case n == 0:
+		return DifficultyUnknown, nil, ErrNoSolution
+	case n > 1:
+		return DifficultyUnknown, nil, ErrMultipleSolutions
+	}
+	sol := &(*allSol)[0]
+
+	if full, err := b.Clone().TrySolveTrivial(); err == nil && full {
+		return DifficultyEasy, sol, nil
+	}
+	return DifficultyHard, sol, nil
+}
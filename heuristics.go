@@ -0,0 +1,197 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+// This file contains the cell selection heuristics of the recursive solver.
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Heuristic is the method used by the recursive solver to select the cell
+// to guess
+type Heuristic int
+
+// Cell selection heuristics
+const (
+	// HeuristicDefault is the default heuristic (HeuristicNeighbours)
+	HeuristicDefault Heuristic = iota
+	// HeuristicFirstEmpty selects the first empty cell in row-major order
+	HeuristicFirstEmpty
+	// HeuristicConstrained selects a cell of the line or column with the
+	// fewest empty cells
+	HeuristicConstrained
+	// HeuristicNeighbours selects the cell with the most filled cells
+	// within a distance of 2 on its line and its column
+	HeuristicNeighbours
+	// HeuristicProbe tries both values on the cells with the most filled
+	// neighbours and selects the cell whose values lead to the most
+	// deductions
+	HeuristicProbe
+)
+
+func (h Heuristic) String() string {
+	switch h {
+	case HeuristicFirstEmpty:
+		return "first"
+	case HeuristicConstrained:
+		return "constrained"
+	case HeuristicNeighbours:
+		return "neighbours"
+	case HeuristicProbe:
+		return "probe"
+	}
+	return "default"
+}
+
+// ParseHeuristic returns the heuristic matching a name
+func ParseHeuristic(name string) (Heuristic, error) {
+	for h := HeuristicDefault; h <= HeuristicProbe; h++ {
+		if h.String() == name {
+			return h, nil
+		}
+	}
+	return HeuristicDefault, errors.Errorf("unknown heuristic '%s'", name)
+}
+
+// probeCandidates is the maximum number of cells probed by HeuristicProbe,
+// per line of the board
+const probeCandidates = 1
+
+// selectCell returns the position of the empty cell the solver should guess,
+// or false if the board is complete.
+func (b Takuzu) selectCell(h Heuristic) (line, col int, ok bool) {
+	switch h {
+	case HeuristicFirstEmpty:
+		for line = 0; line < b.Size; line++ {
+			for col = 0; col < b.Size; col++ {
+				if !b.Board[line][col].Defined {
+					return line, col, true
+				}
+			}
+		}
+		return -1, -1, false
+	case HeuristicConstrained:
+		return b.selectByScore(b.constrainedScores())
+	case HeuristicProbe:
+		return b.selectByProbe()
+	}
+	return b.selectByScore(b.neighboursScore)
+}
+
+// selectByScore returns the empty cell with the highest score (the first
+// one in row-major order in case of a tie)
+func (b Takuzu) selectByScore(score func(l, c int) int) (line, col int, ok bool) {
+	best := 0
+	line, col = -1, -1
+	for l := 0; l < b.Size; l++ {
+		for c := 0; c < b.Size; c++ {
+			if b.Board[l][c].Defined {
+				continue
+			}
+			if s := score(l, c); !ok || s > best {
+				line, col, best, ok = l, c, s, true
+			}
+		}
+	}
+	return
+}
+
+// constrainedScores returns the scoring function of HeuristicConstrained:
+// the fewer empty cells in the most constrained range of the cell, the
+// higher the score; the other range is used to break ties.
+func (b Takuzu) constrainedScores() func(l, c int) int {
+	emptyLines := make([]int, b.Size)
+	emptyColumns := make([]int, b.Size)
+	for l := range b.Board {
+		for c, cell := range b.Board[l] {
+			if !cell.Defined {
+				emptyLines[l]++
+				emptyColumns[c]++
+			}
+		}
+	}
+	return func(l, c int) int {
+		low, high := emptyLines[l], emptyColumns[c]
+		if low > high {
+			low, high = high, low
+		}
+		return -(low*(b.Size+1) + high)
+	}
+}
+
+// neighboursScore is the scoring function of HeuristicNeighbours
+func (b Takuzu) neighboursScore(l, c int) int {
+	n := 0
+	for _, d := range []int{-2, -1, 1, 2} {
+		if l+d >= 0 && l+d < b.Size && b.Board[l+d][c].Defined {
+			n++
+		}
+		if c+d >= 0 && c+d < b.Size && b.Board[l][c+d].Defined {
+			n++
+		}
+	}
+	return n
+}
+
+// selectByProbe implements HeuristicProbe.  A cell whose value leads to a
+// contradiction is selected immediately, as the other value is forced.
+func (b Takuzu) selectByProbe() (line, col int, ok bool) {
+	score := b.neighboursScore
+	var candidates []Position
+	for l := 0; l < b.Size; l++ {
+		for c := 0; c < b.Size; c++ {
+			if !b.Board[l][c].Defined {
+				candidates = append(candidates, Position{l, c})
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return -1, -1, false
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return score(candidates[i].Line, candidates[i].Col) > score(candidates[j].Line, candidates[j].Col)
+	})
+	if len(candidates) > probeCandidates*b.Size {
+		candidates = candidates[:probeCandidates*b.Size]
+	}
+
+	best := -1
+	bx := New(b.Size)
+	for _, p := range candidates {
+		// The score of a cell is the smallest number of cells deduced
+		// with one of its values
+		s := -1
+		for v := 0; v < 2; v++ {
+			Copy(&b, &bx)
+			bx.Set(p.Line, p.Col, v)
+			if _, err := bx.TrySolveTrivial(); err != nil {
+				return p.Line, p.Col, true
+			}
+			if n := countDefined(bx); s < 0 || n < s {
+				s = n
+			}
+		}
+		if s > best {
+			line, col, best = p.Line, p.Col, s
+		}
+	}
+	return line, col, true
+}
+
+// countDefined returns the number of defined cells of the board
+func countDefined(b Takuzu) int {
+	n := 0
+	for l := range b.Board {
+		for _, cell := range b.Board[l] {
+			if cell.Defined {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,290 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+// This file contains the progressive hint system.
+
+import (
+	"fmt"
+)
+
+// Technique is a solving technique, used to explain hints.
+// The techniques are sorted by increasing difficulty.
+type Technique int
+
+// Solving techniques
+const (
+	TechniqueNone Technique = iota
+	// TechniquePair: two adjacent identical values are surrounded by the
+	// other value
+	TechniquePair
+	// TechniqueGap: a cell between two identical values gets the other value
+	TechniqueGap
+	// TechniqueBalance: when a line has all its 0s (or 1s), the remaining
+	// cells are 1s (or 0s)
+	TechniqueBalance
+	// TechniqueElimination: a value would make the line or column break
+	// the rules once completed
+	TechniqueElimination
+	// TechniqueDuplicate: a value would make the line or column identical
+	// to another complete one
+	TechniqueDuplicate
+	// TechniqueTrial: a value leads to a contradiction elsewhere on the
+	// board
+	TechniqueTrial
+	// TechniqueMistake is used for hints about wrong cells
+	TechniqueMistake
+)
+
+func (t Technique) String() string {
+	switch t {
+	case TechniquePair:
+		return "pair"
+	case TechniqueGap:
+		return "gap"
+	case TechniqueBalance:
+		return "balance"
+	case TechniqueElimination:
+		return "elimination"
+	case TechniqueDuplicate:
+		return "duplicate"
+	case TechniqueTrial:
+		return "trial"
+	case TechniqueMistake:
+		return "mistake"
+	}
+	return "none"
+}
+
+// Description returns an explanation of the technique
+func (t Technique) Description() string {
+	switch t {
+	case TechniquePair:
+		return "two identical adjacent values must be surrounded by the other value"
+	case TechniqueGap:
+		return "a cell between two identical values must have the other value"
+	case TechniqueBalance:
+		return "a line with all its 0s or all its 1s can be completed"
+	case TechniqueElimination:
+		return "one of the values would prevent completing the line without breaking the rules"
+	case TechniqueDuplicate:
+		return "one of the values would make two lines or columns identical"
+	case TechniqueTrial:
+		return "one of the values leads to a contradiction on the board"
+	case TechniqueMistake:
+		return "a cell does not match the solution"
+	}
+	return ""
+}
+
+// HintLevel is the level of detail of a hint
+type HintLevel int
+
+// Hint levels
+const (
+	// HintRegion points to the line or column where progress is possible
+	HintRegion HintLevel = iota + 1
+	// HintTechnique also names the technique to use
+	HintTechnique
+	// HintCell reveals the cell and its value
+	HintCell
+)
+
+// Region is a line or a column of a board
+type Region struct {
+	Column bool
+	Index  int
+}
+
+func (r Region) String() string {
+	if r.Column {
+		return fmt.Sprintf("column %d", r.Index)
+	}
+	return fmt.Sprintf("line %d", r.Index)
+}
+
+// Hint is a hint about the next cell that can be deduced, or about a
+// mistake
+type Hint struct {
+	Technique Technique
+	Region    Region
+	Line, Col int
+	Value     int // Deduced value, or correct value for a mistake
+}
+
+// Message returns the text of the hint for the requested level of detail
+func (h Hint) Message(level HintLevel) string {
+	if h.Technique == TechniqueMistake {
+		switch level {
+		case HintRegion, HintTechnique:
+			return fmt.Sprintf("There is a mistake in %v.", h.Region)
+		}
+		return fmt.Sprintf("Line %d, column %d is wrong: it should be %d.",
+			h.Line, h.Col, h.Value)
+	}
+	switch level {
+	case HintRegion:
+		return fmt.Sprintf("Look at %v.", h.Region)
+	case HintTechnique:
+		return fmt.Sprintf("Look at %v: %s (%v).", h.Region, h.Technique.Description(), h.Technique)
+	}
+	return fmt.Sprintf("Line %d, column %d must be %d (%v).", h.Line, h.Col, h.Value, h.Technique)
+}
+
+// rangeHint looks for a deduction on a range (line or column) with the
+// given technique; full contains the complete ranges of the same
+// orientation.  It returns the index of the cell in the range and its
+// value, or -1.
+func rangeHint(cells []Cell, tech Technique, full [][]Cell) (int, int) {
+	n := len(cells)
+	defined := func(i int) bool { return i >= 0 && i < n && cells[i].Defined }
+
+	switch tech {
+	case TechniquePair:
+		for i := 0; i+1 < n; i++ {
+			if !defined(i) || !defined(i+1) || cells[i].Value != cells[i+1].Value {
+				continue
+			}
+			v := 1 - cells[i].Value
+			if i > 0 && !defined(i-1) {
+				return i - 1, v
+			}
+			if i+2 < n && !defined(i+2) {
+				return i + 2, v
+			}
+		}
+	case TechniqueGap:
+		for i := 1; i+1 < n; i++ {
+			if !defined(i) && defined(i-1) && defined(i+1) &&
+				cells[i-1].Value == cells[i+1].Value {
+				return i, 1 - cells[i-1].Value
+			}
+		}
+	case TechniqueBalance:
+		full, n0, n1 := CheckRangeCounts(cells)
+		if full {
+			break
+		}
+		v := -1
+		if n0 == n/2 {
+			v = 1
+		} else if n1 == n/2 {
+			v = 0
+		}
+		if v >= 0 {
+			for i := range cells {
+				if !cells[i].Defined {
+					return i, v
+				}
+			}
+		}
+	case TechniqueDuplicate:
+		// Only ranges with 2 empty cells (one 0 and one 1 missing) are
+		// considered.
+		_, n0, n1 := CheckRangeCounts(cells)
+		if n0 != n/2-1 || n1 != n/2-1 {
+			break
+		}
+		var empty []int
+		for i := range cells {
+			if !cells[i].Defined {
+				empty = append(empty, i)
+			}
+		}
+		for _, f := range full {
+			// Does f match the range when the first empty cell is v?
+			v := f[empty[0]].Value
+			if f[empty[1]].Value != 1-v {
+				continue
+			}
+			match := true
+			for i := range cells {
+				if cells[i].Defined && cells[i].Value != f[i].Value {
+					match = false
+					break
+				}
+			}
+			if match {
+				return empty[0], 1 - v
+			}
+		}
+	}
+	return -1, -1
+}
+
+// Hint returns a hint about a cell that can be deduced from the board,
+// using the easiest technique available.  It returns nil if no hint can be
+// found.  The board should be valid.
+func (b Takuzu) Hint() *Hint {
+	lines := make([][]Cell, b.Size)
+	columns := make([][]Cell, b.Size)
+	var fullLines, fullColumns [][]Cell
+	for i := 0; i < b.Size; i++ {
+		lines[i] = b.GetLine(i)
+		columns[i] = b.GetColumn(i)
+		if full, _, _ := CheckRangeCounts(lines[i]); full {
+			fullLines = append(fullLines, lines[i])
+		}
+		if full, _, _ := CheckRangeCounts(columns[i]); full {
+			fullColumns = append(fullColumns, columns[i])
+		}
+	}
+
+	// Range-based techniques
+	for _, tech := range []Technique{TechniquePair, TechniqueGap, TechniqueBalance, TechniqueDuplicate} {
+		if tech == TechniqueDuplicate {
+			// Elimination is easier than duplicate detection
+			if h := b.cellHint(TechniqueElimination); h != nil {
+				return h
+			}
+		}
+		for i := 0; i < b.Size; i++ {
+			if k, v := rangeHint(lines[i], tech, fullLines); k >= 0 {
+				return &Hint{Technique: tech, Region: Region{Index: i},
+					Line: i, Col: k, Value: v}
+			}
+			if k, v := rangeHint(columns[i], tech, fullColumns); k >= 0 {
+				return &Hint{Technique: tech, Region: Region{Column: true, Index: i},
+					Line: k, Col: i, Value: v}
+			}
+		}
+	}
+
+	return b.cellHint(TechniqueTrial)
+}
+
+// cellHint looks for a cell that can be deduced with a cell-based
+// technique (elimination or trial)
+func (b Takuzu) cellHint(tech Technique) *Hint {
+	bx := New(b.Size)
+	for l := 0; l < b.Size; l++ {
+		for c := 0; c < b.Size; c++ {
+			if b.Board[l][c].Defined {
+				continue
+			}
+			for v := 0; v < 2; v++ {
+				Copy(&b, &bx)
+				bx.Set(l, c, v)
+				if tech == TechniqueElimination {
+					bx.FillLineColumn(l, c)
+					if bx.CheckLine(l) != nil {
+						return &Hint{Technique: tech, Region: Region{Index: l},
+							Line: l, Col: c, Value: 1 - v}
+					}
+					if bx.CheckColumn(c) != nil {
+						return &Hint{Technique: tech, Region: Region{Column: true, Index: c},
+							Line: l, Col: c, Value: 1 - v}
+					}
+					continue
+				}
+				if _, err := bx.TrySolveTrivial(); err != nil {
+					return &Hint{Technique: tech, Region: Region{Index: l},
+						Line: l, Col: c, Value: 1 - v}
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+import (
+	"strings"
+	"testing"
+)
+
+// The hints must agree with the unique solution of the puzzles
+func TestHintMatchesSolution(t *testing.T) {
+	for _, b := range testBoards(t) {
+		solutions := allSolutions(t, b)
+		if len(solutions) != 1 {
+			continue
+		}
+		sol := solutions[0]
+
+		tak := b.Clone()
+		for {
+			h := tak.Hint()
+			if h == nil {
+				break
+			}
+			if h.Technique == TechniqueMistake {
+				t.Fatalf("%s: unexpected mistake hint", b.ToString())
+			}
+			if tak.Board[h.Line][h.Col].Defined {
+				t.Fatalf("%s: hint %+v on a defined cell", b.ToString(), *h)
+			}
+			if want := sol.Board[h.Line][h.Col].Value; h.Value != want {
+				t.Fatalf("%s: hint %+v, want the value %d", b.ToString(), *h, want)
+			}
+			if h.Region.Column && h.Region.Index != h.Col || !h.Region.Column && h.Region.Index != h.Line {
+				t.Fatalf("%s: the cell of the hint %+v is not in its region", b.ToString(), *h)
+			}
+			tak.Set(h.Line, h.Col, h.Value)
+		}
+	}
+}
+
+// The easy puzzles can be solved with the hints only
+func TestHintEasyPuzzles(t *testing.T) {
+	for _, size := range []int{6, 8, 10} {
+		opts := testBuildOptions(size, 1)
+		opts.Simple = true
+		b, err := NewRandomTakuzuWithOptions(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tak := b.Clone()
+		for h := tak.Hint(); h != nil; h = tak.Hint() {
+			tak.Set(h.Line, h.Col, h.Value)
+		}
+		if full, err := tak.Validate(); !full || err != nil {
+			t.Errorf("%s: board %s not completed with the hints (%v)", b.ToString(), tak.ToString(), err)
+		}
+	}
+}
+
+func TestGameHint(t *testing.T) {
+	g := newTestGame(t)
+	g.Play(0, 1, 0) // The solution is 1
+	h, err := g.Hint(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Technique != TechniqueMistake || h.Line != 0 || h.Col != 1 || h.Value != 1 {
+		t.Errorf("unexpected hint %+v", *h)
+	}
+	// The wrong cell is only revealed at the cell level
+	if msg := h.Message(HintRegion); strings.Contains(msg, "column 1") {
+		t.Errorf("the region hint reveals the cell: %q", msg)
+	}
+	if msg := h.Message(HintCell); !strings.Contains(msg, "column 1") {
+		t.Errorf("the cell hint does not reveal the cell: %q", msg)
+	}
+
+	g.Undo()
+	h, err = g.Hint(0)
+	if err != nil || h == nil || h.Technique == TechniqueMistake {
+		t.Fatalf("unexpected hint %+v (%v)", h, err)
+	}
+	if want := int(testSolution[h.Line*6+h.Col] - '0'); h.Value != want {
+		t.Errorf("hint %+v, want the value %d", *h, want)
+	}
+	for _, level := range []HintLevel{HintRegion, HintTechnique, HintCell} {
+		if h.Message(level) == "" {
+			t.Errorf("empty message at level %d", level)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+// This file contains the HTML and Markdown writers.
+
+import (
+	"bufio"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// HTMLOptions contains the options of the HTML writer
+type HTMLOptions struct {
+	// Class is the CSS class of the table; the default is "takuzu".
+	// It is escaped in the HTML output.
+	Class string
+	// Puzzle is the optional initial board.  If it is set, the cells that
+	// are not given in the puzzle get the "filled" class instead of the
+	// "given" class.
+	Puzzle *Takuzu
+	// ShowErrors adds the "error" class to the cells breaking the rules
+	ShowErrors bool
+}
+
+// MarkdownOptions contains the options of the Markdown writer
+type MarkdownOptions struct {
+	// Puzzle is the optional initial board.  If it is set, only the cells
+	// given in the puzzle are written in bold.
+	Puzzle *Takuzu
+	// ShowErrors strikes through the cells breaking the rules
+	ShowErrors bool
+}
+
+// cellClasses returns the CSS-like classes of the cells
+func (b Takuzu) cellClasses(puzzle *Takuzu, showErrors bool) [][]string {
+	cls := make([][]string, b.Size)
+	for l := range b.Board {
+		cls[l] = make([]string, b.Size)
+		for c, cell := range b.Board[l] {
+			switch {
+			case !cell.Defined:
+				cls[l][c] = "empty"
+			case puzzle != nil && puzzle.Size == b.Size && !puzzle.Board[l][c].Defined:
+				cls[l][c] = "filled"
+			default:
+				cls[l][c] = "given"
+			}
+		}
+	}
+	if showErrors {
+		for _, p := range b.ErrorCells() {
+			cls[p.Line][p.Col] += " error"
+		}
+	}
+	return cls
+}
+
+// WriteHTML writes the board as an HTML table.
+// The cells have a "given", "filled" or "empty" class, and optionally an
+// "error" class.
+func (b Takuzu) WriteHTML(w io.Writer, opts HTMLOptions) error {
+	class := opts.Class
+	if class == "" {
+		class = "takuzu"
+	}
+	cls := b.cellClasses(opts.Puzzle, opts.ShowErrors)
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(`<table class="` + html.EscapeString(class) + `">` + "\n")
+	for l := range b.Board {
+		bw.WriteString("<tr>")
+		for c, cell := range b.Board[l] {
+			bw.WriteString(`<td class="` + cls[l][c] + `">`)
+			if cell.Defined {
+				bw.WriteString(strconv.Itoa(cell.Value))
+			}
+			bw.WriteString("</td>")
+		}
+		bw.WriteString("</tr>\n")
+	}
+	bw.WriteString("</table>\n")
+	return bw.Flush()
+}
+
+// WriteMarkdown writes the board as a Markdown table.
+// Given cells are written in bold, and cells breaking the rules can be
+// struck through.
+func (b Takuzu) WriteMarkdown(w io.Writer, opts MarkdownOptions) error {
+	cls := b.cellClasses(opts.Puzzle, opts.ShowErrors)
+
+	bw := bufio.NewWriter(w)
+
+	// Header, with the column numbers
+	bw.WriteString("|   |")
+	for c := 0; c < b.Size; c++ {
+		bw.WriteString(" " + strconv.Itoa(c) + " |")
+	}
+	bw.WriteString("\n|---|")
+	for c := 0; c < b.Size; c++ {
+		bw.WriteString(":-:|")
+	}
+	bw.WriteString("\n")
+
+	for l := range b.Board {
+		bw.WriteString("| " + strconv.Itoa(l) + " |")
+		for c, cell := range b.Board[l] {
+			if !cell.Defined {
+				bw.WriteString("   |")
+				continue
+			}
+			v := strconv.Itoa(cell.Value)
+			if strings.HasPrefix(cls[l][c], "given") {
+				v = "**" + v + "**"
+			}
+			if strings.HasSuffix(cls[l][c], " error") {
+				v = "~~" + v + "~~"
+			}
+			bw.WriteString(" " + v + " |")
+		}
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,175 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+// This file contains the functions used to render a board as a raster image.
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// ImageTheme is the visual style used to render a board
+type ImageTheme int
+
+const (
+	// ThemeClassic renders cells with 0 and 1 digits
+	ThemeClassic ImageTheme = iota
+	// ThemeDiscs renders cells with coloured discs (as in 0h h1)
+	ThemeDiscs
+)
+
+// DefaultImageCellSize is the default cell size in pixels
+const DefaultImageCellSize = 40
+
+// ImageOptions contains the rendering options for Image
+type ImageOptions struct {
+	// CellSize is the size of a cell in pixels; the default is used if 0
+	CellSize int
+	// Theme is the visual style of the cells
+	Theme ImageTheme
+	// Puzzle is the optional initial board.  If it is set, the cells
+	// that are not given in the puzzle are rendered with a lighter colour,
+	// which is useful to render solutions.
+	Puzzle *Takuzu
+}
+
+var (
+	imgBackground  = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	imgGridColor   = color.RGBA{0xb0, 0xb0, 0xb0, 0xff}
+	imgBorderColor = color.RGBA{0x30, 0x30, 0x30, 0xff}
+	imgGivenDigit  = color.RGBA{0x10, 0x10, 0x10, 0xff}
+	imgSolvedDigit = color.RGBA{0x30, 0x60, 0xc0, 0xff}
+	imgEmptyDisc   = color.RGBA{0xe4, 0xe4, 0xe4, 0xff}
+	imgGivenDiscs  = [2]color.RGBA{{0xc2, 0x41, 0x3a, 0xff}, {0x30, 0x6c, 0xb8, 0xff}}
+	imgSolvedDiscs = [2]color.RGBA{{0xe0, 0x8a, 0x84, 0xff}, {0x84, 0xa8, 0xdc, 0xff}}
+)
+
+// Image returns a raster rendering of the board
+func (b Takuzu) Image(opts ImageOptions) image.Image {
+	cs := opts.CellSize
+	if cs <= 0 {
+		cs = DefaultImageCellSize
+	}
+	if cs < 8 {
+		cs = 8
+	}
+	border := cs / 16
+	if border < 1 {
+		border = 1
+	}
+
+	width := b.Size*cs + 2*border
+	img := image.NewRGBA(image.Rect(0, 0, width, width))
+	draw.Draw(img, img.Bounds(), &image.Uniform{imgBackground}, image.Point{}, draw.Src)
+
+	// Inner grid lines
+	for i := 1; i < b.Size; i++ {
+		p := border + i*cs
+		fillRect(img, image.Rect(p, 0, p+1, width), imgGridColor)
+		fillRect(img, image.Rect(0, p, width, p+1), imgGridColor)
+	}
+	// Outer border
+	fillRect(img, image.Rect(0, 0, width, border), imgBorderColor)
+	fillRect(img, image.Rect(0, width-border, width, width), imgBorderColor)
+	fillRect(img, image.Rect(0, 0, border, width), imgBorderColor)
+	fillRect(img, image.Rect(width-border, 0, width, width), imgBorderColor)
+
+	for l := range b.Board {
+		for c, cell := range b.Board[l] {
+			r := image.Rect(border+c*cs+1, border+l*cs+1, border+(c+1)*cs, border+(l+1)*cs)
+			given := true
+			if opts.Puzzle != nil && opts.Puzzle.Size == b.Size {
+				given = opts.Puzzle.Board[l][c].Defined
+			}
+			switch opts.Theme {
+			case ThemeDiscs:
+				drawDiscCell(img, r, cell, given)
+			default:
+				drawDigitCell(img, r, cell, given)
+			}
+		}
+	}
+	return img
+}
+
+// drawDigitCell draws a 0 or a 1 in the rectangle r
+func drawDigitCell(img *image.RGBA, r image.Rectangle, cell Cell, given bool) {
+	if !cell.Defined {
+		return
+	}
+	col := imgGivenDigit
+	if !given {
+		col = imgSolvedDigit
+	}
+
+	w, h := r.Dx(), r.Dy()
+	cx, cy := float64(r.Min.X)+float64(w)/2, float64(r.Min.Y)+float64(h)/2
+	ry := float64(h) * 0.30
+	rx := ry * 0.62
+	stroke := float64(h) / 9
+	if stroke < 1 {
+		stroke = 1
+	}
+
+	if cell.Value == 0 {
+		// Elliptic ring
+		fillEllipse(img, r, cx, cy, rx, ry, col)
+		if rx > stroke && ry > stroke {
+			fillEllipse(img, r, cx, cy, rx-stroke, ry-stroke, imgBackground)
+		}
+		return
+	}
+
+	// Vertical stroke with a small flag
+	x0 := int(cx - stroke/2)
+	x1 := int(cx + stroke/2 + 0.5)
+	y0 := int(cy - ry)
+	y1 := int(cy + ry + 0.5)
+	fillRect(img, image.Rect(x0, y0, x1, y1), col)
+	flag := int(rx)
+	for i := 0; i < flag; i++ {
+		fillRect(img, image.Rect(x0-i-1, y0+i, x0-i+int(stroke), y0+i+1), col)
+	}
+}
+
+// drawDiscCell draws a coloured disc in the rectangle r
+func drawDiscCell(img *image.RGBA, r image.Rectangle, cell Cell, given bool) {
+	w, h := r.Dx(), r.Dy()
+	cx, cy := float64(r.Min.X)+float64(w)/2, float64(r.Min.Y)+float64(h)/2
+	rad := float64(h) * 0.40
+
+	col := imgEmptyDisc
+	if cell.Defined {
+		if given {
+			col = imgGivenDiscs[cell.Value]
+		} else {
+			col = imgSolvedDiscs[cell.Value]
+		}
+	}
+	fillEllipse(img, r, cx, cy, rad, rad, col)
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, col color.RGBA) {
+	draw.Draw(img, r.Intersect(img.Bounds()), &image.Uniform{col}, image.Point{}, draw.Src)
+}
+
+// fillEllipse fills the ellipse of center (cx, cy) and radii (rx, ry),
+// clipped to the rectangle clip.
+func fillEllipse(img *image.RGBA, clip image.Rectangle, cx, cy, rx, ry float64, col color.RGBA) {
+	if rx <= 0 || ry <= 0 {
+		return
+	}
+	for y := clip.Min.Y; y < clip.Max.Y; y++ {
+		dy := (float64(y) + 0.5 - cy) / ry
+		for x := clip.Min.X; x < clip.Max.X; x++ {
+			dx := (float64(x) + 0.5 - cx) / rx
+			if dx*dx+dy*dy <= 1 {
+				img.SetRGBA(x, y, col)
+			}
+		}
+	}
+}
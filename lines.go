@@ -0,0 +1,240 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+// This file contains the tables of the valid lines.
+// For a given size, the valid lines (balanced and without triples) are
+// stored as bitmasks: bit i is the value of cell i.  The tables are built
+// once per size and shared by all the goroutines.
+
+import (
+	"sort"
+	"sync"
+)
+
+// MaxLinePatternSize is the largest board size for which the table of the
+// valid lines is built (there are 52404 valid lines of size 24).
+const MaxLinePatternSize = 24
+
+// lineTable is the table of the valid lines of a given size
+type lineTable struct {
+	once     sync.Once
+	patterns []uint64
+}
+
+// lineTables maps the sizes to their *lineTable
+var lineTables sync.Map
+
+// LinePatterns returns the sorted list of the valid lines of the given size,
+// as bitmasks.  It returns nil if the size is odd or larger than
+// MaxLinePatternSize.  The slice is shared and must not be modified.
+func LinePatterns(size int) []uint64 {
+	if size <= 0 || size%2 != 0 || size > MaxLinePatternSize {
+		return nil
+	}
+
+	v, ok := lineTables.Load(size)
+	if !ok {
+		v, _ = lineTables.LoadOrStore(size, &lineTable{})
+	}
+	t := v.(*lineTable)
+	t.once.Do(func() {
+		t.patterns = buildLinePatterns(size)
+	})
+	return t.patterns
+}
+
+// buildLinePatterns enumerates the valid lines of the given size
+func buildLinePatterns(size int) []uint64 {
+	var patterns []uint64
+	var build func(i int, s rangeState, bits uint64)
+	build = func(i int, s rangeState, bits uint64) {
+		if i == size {
+			patterns = append(patterns, bits)
+			return
+		}
+		for v := 0; v < 2; v++ {
+			if ns, ok := s.next(v, i, size); ok {
+				build(i+1, ns, bits|uint64(v)<<uint(i))
+			}
+		}
+	}
+	build(0, rangeState{}, 0)
+	sort.Slice(patterns, func(i, j int) bool { return patterns[i] < patterns[j] })
+	return patterns
+}
+
+// IsValidLine returns true if the bitmask is a valid line of the given
+// size, using the table of the valid lines.  The second value is false if
+// there is no table for this size.
+func IsValidLine(size int, bits uint64) (valid, ok bool) {
+	patterns := LinePatterns(size)
+	if patterns == nil {
+		return false, false
+	}
+	i := sort.Search(len(patterns), func(i int) bool { return patterns[i] >= bits })
+	return i < len(patterns) && patterns[i] == bits, true
+}
+
+// rangeBits returns the values and the mask of the defined cells of a
+// range, as bitmasks.  The range must not be larger than 64 cells.
+func rangeBits(cells []Cell) (bits, defined uint64) {
+	for i, c := range cells {
+		if !c.Defined {
+			continue
+		}
+		defined |= 1 << uint(i)
+		if c.Value == 1 {
+			bits |= 1 << uint(i)
+		}
+	}
+	return
+}
+
+// patternCompletions returns the bitmasks of the cells that are 1 in all
+// the valid completions of the range and of the cells that are 1 in at
+// least one of them, using the table of the valid lines.  The complete
+// ranges in full are not valid completions.  The number of completions is
+// also returned.
+func patternCompletions(patterns []uint64, cells []Cell, full map[uint64]bool) (and, or uint64, n int) {
+	bits, defined := rangeBits(cells)
+	and = ^uint64(0)
+	for _, p := range patterns {
+		if p&defined != bits || full[p] {
+			continue
+		}
+		and &= p
+		or |= p
+		n++
+	}
+	return
+}
+
+// randomSolutionBudget is the number of rows tried by randomSolution before
+// restarting from an empty board, per row of the board
+const randomSolutionBudget = 20
+
+// randomSolution builds a random complete board, row by row, from the valid
+// lines.  Each row is chosen so that all the columns can still be completed;
+// the uniqueness of the columns is checked when the board is complete.  The
+// search is restarted when it gets stuck.
+// It returns nil if there is no table of valid lines for this size.
+func randomSolution(size int, rng randSource) *Takuzu {
+	patterns := LinePatterns(size)
+	if patterns == nil {
+		return nil
+	}
+	all := uint64(1)<<uint(size) - 1
+
+	// completable tells if a column prefix of length i in state s can be
+	// completed
+	memo := make(map[[2]int]bool)
+	var completable func(s rangeState, i int) bool
+	completable = func(s rangeState, i int) bool {
+		if i == size {
+			return true
+		}
+		key := [2]int{s.index(), i}
+		if ok, found := memo[key]; found {
+			return ok
+		}
+		ok := false
+		for v := 0; v < 2 && !ok; v++ {
+			if ns, valid := s.next(v, i, size); valid {
+				ok = completable(ns, i+1)
+			}
+		}
+		memo[key] = ok
+		return ok
+	}
+
+	rows := make([]uint64, size)
+	used := make(map[uint64]bool)
+	columns := make([]rangeState, size)
+	var tries int
+
+	var fill func(r int) bool
+	fill = func(r int) bool {
+		if r == size {
+			return columnsDistinct(rows, size)
+		}
+		if tries > randomSolutionBudget*size {
+			return false
+		}
+		tries++
+
+		// Columns that cannot get a 0 or a 1 in this row
+		var no0, no1 uint64
+		for c, cs := range columns {
+			bit := uint64(1) << uint(c)
+			if ns, ok := cs.next(0, r, size); !ok || !completable(ns, r+1) {
+				no0 |= bit
+			}
+			if ns, ok := cs.next(1, r, size); !ok || !completable(ns, r+1) {
+				no1 |= bit
+			}
+		}
+
+		var candidates []uint64
+		for _, p := range patterns {
+			if p&no1 == 0 && ^p&no0&all == 0 && !used[p] {
+				candidates = append(candidates, p)
+			}
+		}
+
+		// Try the candidates in random order
+		saved := append([]rangeState(nil), columns...)
+		for n := len(candidates); n > 0; n-- {
+			i := rng.Intn(n)
+			p := candidates[i]
+			candidates[i] = candidates[n-1]
+
+			rows[r] = p
+			used[p] = true
+			for c := range columns {
+				columns[c], _ = columns[c].next(int(p>>uint(c)&1), r, size)
+			}
+			if fill(r + 1) {
+				return true
+			}
+			copy(columns, saved)
+			delete(used, p)
+		}
+		return false
+	}
+
+	for !fill(0) {
+		if tries <= randomSolutionBudget*size {
+			// The search space has been exhausted
+			return nil
+		}
+		tries = 0
+	}
+
+	tak := New(size)
+	for l := range tak.Board {
+		for c := range tak.Board[l] {
+			tak.Board[l][c].Set(int(rows[l] >> uint(c) & 1))
+		}
+	}
+	return &tak
+}
+
+// columnsDistinct returns true if the columns of a complete board, given as
+// row bitmasks, are all different
+func columnsDistinct(rows []uint64, size int) bool {
+	seen := make(map[uint64]bool)
+	for c := 0; c < size; c++ {
+		var col uint64
+		for l := 0; l < size; l++ {
+			col |= (rows[l] >> uint(c) & 1) << uint(l)
+		}
+		if seen[col] {
+			return false
+		}
+		seen[col] = true
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// bitsRange returns the cells of a complete range
+func bitsRange(size int, bits uint64) []Cell {
+	cells := make([]Cell, size)
+	for i := range cells {
+		cells[i].Set(int(bits >> uint(i) & 1))
+	}
+	return cells
+}
+
+func TestLinePatterns(t *testing.T) {
+	for size, want := range map[int]int{2: 2, 4: 6, 6: 14, 8: 34, 10: 84, 12: 208, 24: 52404} {
+		if n := len(LinePatterns(size)); n != want {
+			t.Errorf("size %d: %d patterns, want %d", size, n, want)
+		}
+	}
+	for _, size := range []int{-2, 0, 5, MaxLinePatternSize + 2} {
+		if LinePatterns(size) != nil {
+			t.Errorf("size %d: unexpected table", size)
+		}
+		if _, ok := IsValidLine(size, 0); ok {
+			t.Errorf("size %d: IsValidLine has a table", size)
+		}
+	}
+}
+
+// IsValidLine must agree with the rules checked by Validate for all the
+// lines of the small sizes
+func TestIsValidLine(t *testing.T) {
+	for size := 2; size <= 12; size += 2 {
+		n := 0
+		for bits := uint64(0); bits < 1<<uint(size); bits++ {
+			valid, ok := IsValidLine(size, bits)
+			if !ok {
+				t.Fatalf("size %d: no table", size)
+			}
+			_, err := checkRange(bitsRange(size, bits))
+			if valid != (err == nil) {
+				t.Errorf("size %d: IsValidLine(%b) returned %v (%v)", size, bits, valid, err)
+			}
+			if valid {
+				n++
+			}
+		}
+		if n != len(LinePatterns(size)) {
+			t.Errorf("size %d: %d valid lines, %d patterns", size, n, len(LinePatterns(size)))
+		}
+	}
+
+	// Boards with a complete first line
+	for _, bits := range []uint64{0x0f, 0x33, 0x35, 0x2d, 0x1e, 0x27} {
+		b := New(8)
+		for c, cell := range bitsRange(8, bits) {
+			b.Set(0, c, cell.Value)
+		}
+		valid, _ := IsValidLine(8, bits)
+		if _, err := b.Validate(); valid != (err == nil) {
+			t.Errorf("line %08b: IsValidLine returned %v, Validate returned %v", bits, valid, err)
+		}
+	}
+}
+
+// randomRange returns a range with some random cells defined
+func randomRange(rng *rand.Rand, size int) []Cell {
+	cells := make([]Cell, size)
+	for i := range cells {
+		if rng.Intn(3) == 0 {
+			cells[i].Set(rng.Intn(2))
+		}
+	}
+	return cells
+}
+
+// The dynamic programming must agree with the table of the valid lines,
+// above the size where the propagation engine switches to it
+func TestRangeCompletions(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, size := range []int{6, 12, 18, 20, 24} {
+		patterns := LinePatterns(size)
+		for k := 0; k < 50; k++ {
+			cells := randomRange(rng, size)
+
+			// A few distinct complete ranges are excluded
+			var full [][]Cell
+			fullBits := make(map[uint64]bool)
+			for len(full) < 3 {
+				p := patterns[rng.Intn(len(patterns))]
+				if !fullBits[p] {
+					full = append(full, bitsRange(size, p))
+					fullBits[p] = true
+				}
+			}
+
+			counts, total := rangeCompletions(cells, full)
+			and, or, n := patternCompletions(patterns, cells, fullBits)
+			if total != uint64(n) {
+				t.Errorf("size %d: %d completions, want %d", size, total, n)
+				continue
+			}
+			if n == 0 {
+				continue
+			}
+			for i := 0; i < size; i++ {
+				if can1 := or>>uint(i)&1 == 1; can1 != (counts[i][1] > 0) {
+					t.Errorf("size %d, cell %d: %d completions with 1", size, i, counts[i][1])
+				}
+				if can0 := and>>uint(i)&1 == 0; can0 != (counts[i][0] > 0) {
+					t.Errorf("size %d, cell %d: %d completions with 0", size, i, counts[i][0])
+				}
+				if counts[i][0]+counts[i][1] != total {
+					t.Errorf("size %d, cell %d: inconsistent counts", size, i)
+				}
+			}
+		}
+	}
+
+	// The counters saturate instead of overflowing
+	for _, size := range []int{48, 64} {
+		counts, total := rangeCompletions(make([]Cell, size), nil)
+		if total != completionCap {
+			t.Errorf("size %d: %d completions, want %d", size, total, uint64(completionCap))
+		}
+		for i := range counts {
+			if counts[i][0] == 0 || counts[i][0] > completionCap ||
+				counts[i][1] == 0 || counts[i][1] > completionCap {
+				t.Errorf("size %d, cell %d: counts %v", size, i, counts[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+// This file contains the failed-literal probing.
+// A value is tentatively set in an empty cell and the consequences are
+// computed on the whole board; if this leads to a contradiction, the cell
+// gets the other value.  At depth 1, the consequences are computed with the
+// propagation engine only; at depth N, the tentative boards can be probed
+// themselves at depth N-1.
+
+import (
+	"log"
+
+	"github.com/pkg/errors"
+)
+
+// ProbeStep is a cell deduced by SolveProbe
+type ProbeStep struct {
+	Line  int `json:"line"`
+	Col   int `json:"col"`
+	Value int `json:"value"`
+	// Depth is the probing depth required to deduce the cell (0 means the
+	// propagation engine was enough)
+	Depth int `json:"depth"`
+}
+
+// SolveProbe tries to solve the takuzu without guessing, using the
+// propagation engine and failed-literal probing up to maxDepth levels.
+// The shallowest deduction is always preferred.  It returns the deduced
+// cells in order, true if all cells are defined, and an error if the board
+// cannot be completed.
+// Note: This method updates b.
+func (b Takuzu) SolveProbe(maxDepth int) ([]ProbeStep, bool, error) {
+	var steps []ProbeStep
+	before := New(b.Size)
+	for {
+		Copy(&b, &before)
+		if _, err := b.Propagate(); err != nil {
+			return steps, false, errors.Wrap(err, "the takuzu looks wrong")
+		}
+		for _, p := range BoardsDiff(&before, &b, false) {
+			steps = append(steps, ProbeStep{p.Line, p.Col, b.Board[p.Line][p.Col].Value, 0})
+		}
+		full, err := b.Validate()
+		if err != nil {
+			return steps, false, errors.Wrap(err, "the takuzu looks wrong")
+		}
+		if full {
+			return steps, true, nil
+		}
+
+		found := false
+		for depth := 1; depth <= maxDepth && !found; depth++ {
+			var line, col, value int
+			if line, col, value, found = b.failedLiteral(depth); found {
+				if verbosity > 2 {
+					log.Printf("Probe: Setting [%d,%d] to %d (depth %d)", line, col, value, depth)
+				}
+				b.Set(line, col, value)
+				steps = append(steps, ProbeStep{line, col, value, depth})
+			}
+		}
+		if !found {
+			return steps, false, nil
+		}
+	}
+}
+
+// failedLiteral looks for an empty cell where one of the values is refuted
+// by probing at the given depth.  It returns the cell and its other value.
+func (b Takuzu) failedLiteral(depth int) (line, col, value int, ok bool) {
+	bx := New(b.Size)
+	for line = 0; line < b.Size; line++ {
+		for col = 0; col < b.Size; col++ {
+			if b.Board[line][col].Defined {
+				continue
+			}
+			for v := 0; v < 2; v++ {
+				Copy(&b, &bx)
+				bx.Set(line, col, v)
+				if bx.refuted(depth - 1) {
+					return line, col, 1 - v, true
+				}
+			}
+		}
+	}
+	return -1, -1, -1, false
+}
+
+// refuted returns true if probing at the given depth leads to a
+// contradiction.
+// Note: This method updates b.
+func (b Takuzu) refuted(depth int) bool {
+	for {
+		if _, err := b.Propagate(); err != nil {
+			return true
+		}
+		if _, err := b.Validate(); err != nil {
+			return true
+		}
+		if depth == 0 {
+			return false
+		}
+		line, col, value, ok := b.failedLiteral(depth)
+		if !ok {
+			return false
+		}
+		b.Set(line, col, value)
+	}
+}
+
+// ProbeDepth returns the probing depth required to solve the puzzle without
+// guessing, i.e. the largest depth of the steps of SolveProbe.
+// It returns -1 if the puzzle cannot be solved with maxDepth levels.
+func (b Takuzu) ProbeDepth(maxDepth int) (int, error) {
+	steps, full, err := b.Clone().SolveProbe(maxDepth)
+	if err != nil {
+		return -1, err
+	}
+	return StepsDepth(steps, full), nil
+}
+
+// StepsDepth returns the probing depth of a SolveProbe result: the largest
+// depth of the steps, or -1 if the board was not completed.
+func StepsDepth(steps []ProbeStep, full bool) int {
+	if !full {
+		return -1
+	}
+	depth := 0
+	for _, s := range steps {
+		if s.Depth > depth {
+			depth = s.Depth
+		}
+	}
+	return depth
+}
@@ -0,0 +1,61 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+import "testing"
+
+func TestSolveProbe(t *testing.T) {
+	for _, b := range testBoards(t) {
+		solutions := allSolutions(t, b)
+		for depth := 0; depth <= 2; depth++ {
+			p := b.Clone()
+			steps, full, err := p.SolveProbe(depth)
+			if len(solutions) == 0 {
+				if err == nil {
+					t.Errorf("%s (depth %d): no contradiction found", b.ToString(), depth)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s (depth %d): SolveProbe: %v", b.ToString(), depth, err)
+				continue
+			}
+			checkForcedCells(t, b.ToString(), &p, solutions)
+			if full && len(solutions) != 1 {
+				t.Errorf("%s (depth %d): board completed with %d solutions",
+					b.ToString(), depth, len(solutions))
+			}
+
+			// The steps are the cells that have been set
+			if len(steps) != len(BoardsDiff(&b, &p, false)) {
+				t.Errorf("%s (depth %d): %d steps for %d cells",
+					b.ToString(), depth, len(steps), len(BoardsDiff(&b, &p, false)))
+			}
+			for _, s := range steps {
+				if s.Depth > depth || p.Board[s.Line][s.Col].Value != s.Value {
+					t.Errorf("%s (depth %d): unexpected step %+v", b.ToString(), depth, s)
+				}
+			}
+			if d := StepsDepth(steps, full); full && d > depth || !full && d != -1 {
+				t.Errorf("%s (depth %d): StepsDepth returned %d", b.ToString(), depth, d)
+			}
+		}
+	}
+}
+
+func TestProbeDepth(t *testing.T) {
+	// This puzzle needs probing
+	b, err := NewRandomTakuzuWithOptions(testBuildOptions(10, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := b.ProbeDepth(3)
+	if err != nil || d < 1 {
+		t.Fatalf("ProbeDepth returned %d (%v)", d, err)
+	}
+	if n, err := b.ProbeDepth(d - 1); err != nil || n != -1 {
+		t.Errorf("ProbeDepth(%d) returned %d (%v), want -1", d-1, n, err)
+	}
+}
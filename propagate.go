@@ -0,0 +1,341 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+// This file contains the constraint propagation engine.
+// For each partially filled line or column, the valid completions (balanced,
+// without triples and different from the complete ranges of the same
+// orientation) are considered: a cell is forced when all the completions
+// agree on its value.  For small boards, the completions are enumerated
+// from the table of the valid lines; otherwise they are counted per cell
+// value with dynamic programming over the range, so that they do not have
+// to be enumerated.
+
+import (
+	"log"
+
+	"github.com/pkg/errors"
+)
+
+// tablePropagationMaxSize is the largest size for which the propagation
+// engine uses the table of the valid lines; the dynamic programming is
+// faster for larger sizes.
+const tablePropagationMaxSize = 16
+
+// completionCap is the saturation value of the completion counters
+const completionCap = 1 << 31
+
+// satAdd and satMul are saturating operations on completion counters
+func satAdd(a, b uint64) uint64 {
+	if a+b > completionCap {
+		return completionCap
+	}
+	return a + b
+}
+
+func satMul(a, b uint64) uint64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	if a >= completionCap || b >= completionCap || a*b > completionCap {
+		return completionCap
+	}
+	return a * b
+}
+
+// rangeState is the state of a range prefix: the number of 0s, the last
+// value and the length of the last run (0 for an empty prefix)
+type rangeState struct {
+	n0, last, run int
+}
+
+// index returns the index of the state in the counters arrays
+func (s rangeState) index() int {
+	return (s.n0*2+s.last)*3 + s.run
+}
+
+// next returns the state after appending the value v to a prefix of length
+// i, and false if the rules are broken.
+func (s rangeState) next(v, i, size int) (rangeState, bool) {
+	ns := rangeState{n0: s.n0, last: v, run: 1}
+	if v == 0 {
+		ns.n0++
+	}
+	if ns.n0 > size/2 || i+1-ns.n0 > size/2 {
+		return ns, false
+	}
+	if s.run > 0 && s.last == v {
+		ns.run = s.run + 1
+		if ns.run > 2 {
+			return ns, false
+		}
+	}
+	return ns, true
+}
+
+// rangeCompletions returns, for each cell of the range and each value, the
+// number of valid completions of the range with this value (saturated).
+// The complete ranges in full are not counted as completions.
+// The total number of completions is also returned.
+func rangeCompletions(cells []Cell, full [][]Cell) ([][2]uint64, uint64) {
+	size := len(cells)
+	nStates := (size/2 + 1) * 6
+
+	allowed := func(i, v int) bool {
+		return !cells[i].Defined || cells[i].Value == v
+	}
+
+	// forward[i][s]: number of valid prefixes of length i ending in state s
+	// backward[i][s]: number of valid suffixes from position i, after a
+	// prefix in state s
+	forward := make([][]uint64, size+1)
+	backward := make([][]uint64, size+1)
+	states := make([][]rangeState, size+1)
+	for i := range forward {
+		forward[i] = make([]uint64, nStates)
+		backward[i] = make([]uint64, nStates)
+	}
+
+	seen := make([]bool, nStates)
+	states[0] = []rangeState{{}}
+	forward[0][rangeState{}.index()] = 1
+	for i := 0; i < size; i++ {
+		for k := range seen {
+			seen[k] = false
+		}
+		for _, s := range states[i] {
+			for v := 0; v < 2; v++ {
+				if !allowed(i, v) {
+					continue
+				}
+				ns, ok := s.next(v, i, size)
+				if !ok {
+					continue
+				}
+				k := ns.index()
+				forward[i+1][k] = satAdd(forward[i+1][k], forward[i][s.index()])
+				if !seen[k] {
+					seen[k] = true
+					states[i+1] = append(states[i+1], ns)
+				}
+			}
+		}
+	}
+
+	for _, s := range states[size] {
+		backward[size][s.index()] = 1
+	}
+	for i := size - 1; i >= 0; i-- {
+		for _, s := range states[i] {
+			var n uint64
+			for v := 0; v < 2; v++ {
+				if !allowed(i, v) {
+					continue
+				}
+				if ns, ok := s.next(v, i, size); ok {
+					n = satAdd(n, backward[i+1][ns.index()])
+				}
+			}
+			backward[i][s.index()] = n
+		}
+	}
+
+	counts := make([][2]uint64, size)
+	for i := 0; i < size; i++ {
+		for _, s := range states[i] {
+			for v := 0; v < 2; v++ {
+				if !allowed(i, v) {
+					continue
+				}
+				if ns, ok := s.next(v, i, size); ok {
+					n := satMul(forward[i][s.index()], backward[i+1][ns.index()])
+					counts[i][v] = satAdd(counts[i][v], n)
+				}
+			}
+		}
+	}
+	total := backward[0][rangeState{}.index()]
+
+	// The complete ranges that match the cells are not valid completions
+	// (no duplicate rule).  Saturated counters are large enough not to
+	// be affected.
+	for _, f := range full {
+		match := true
+		for i := range cells {
+			if !cellsMatch(cells[i], f[i], true) {
+				match = false
+				break
+			}
+		}
+		if !match {
+			continue
+		}
+		if _, err := checkRange(f); err != nil {
+			continue // Not counted by the dynamic programming
+		}
+		for i := range cells {
+			if counts[i][f[i].Value] > 0 && counts[i][f[i].Value] < completionCap {
+				counts[i][f[i].Value]--
+			}
+		}
+		if total > 0 && total < completionCap {
+			total--
+		}
+	}
+	return counts, total
+}
+
+// propagateRange forces the cells of a range whose value is the same in all
+// the valid completions.  It returns the number of cells set, and an error
+// if the range cannot be completed.
+// If the table of the valid lines (patterns) is provided, fullBits must
+// contain the bitmasks of the complete ranges.
+func propagateRange(cells []*Cell, full [][]Cell, patterns []uint64, fullBits map[uint64]bool) (int, error) {
+	values := make([]Cell, len(cells))
+	for i, c := range cells {
+		values[i] = *c
+	}
+
+	if patterns != nil {
+		and, or, total := patternCompletions(patterns, values, fullBits)
+		if total == 0 {
+			return 0, errors.New("no valid completion")
+		}
+		n := 0
+		for i, c := range cells {
+			if c.Defined {
+				continue
+			}
+			switch {
+			case and>>uint(i)&1 == 1:
+				c.Set(1)
+				n++
+			case or>>uint(i)&1 == 0:
+				c.Set(0)
+				n++
+			}
+		}
+		return n, nil
+	}
+
+	counts, total := rangeCompletions(values, full)
+	if total == 0 {
+		return 0, errors.New("no valid completion")
+	}
+	n := 0
+	for i, c := range cells {
+		if c.Defined {
+			continue
+		}
+		switch {
+		case counts[i][0] == 0 && counts[i][1] == 0:
+			return n, errors.New("no valid completion")
+		case counts[i][0] == 0:
+			c.Set(1)
+			n++
+		case counts[i][1] == 0:
+			c.Set(0)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// sameRange returns true if the complete ranges a and b are identical
+func sameRange(a, b []Cell) bool {
+	for i := range a {
+		if a[i].Value != b[i].Value {
+			return false
+		}
+	}
+	return true
+}
+
+// Propagate applies the propagation engine to the lines and the columns of
+// the board until no more cells can be forced.  It returns the number of
+// cells that have been set, and an error if the board cannot be completed.
+// Note: This method updates b.
+func (b Takuzu) Propagate() (int, error) {
+	var patterns []uint64
+	if b.Size <= tablePropagationMaxSize {
+		patterns = LinePatterns(b.Size)
+	}
+
+	total := 0
+	for {
+		n := 0
+		for _, column := range []bool{false, true} {
+			what := "line"
+			if column {
+				what = "column"
+			}
+
+			// Complete ranges of the current orientation
+			var full [][]Cell
+			fullBits := make(map[uint64]bool)
+			ranges := make([][]*Cell, b.Size)
+			for i := 0; i < b.Size; i++ {
+				var cells []Cell
+				if column {
+					ranges[i] = b.GetColumnPointers(i)
+					cells = b.GetColumn(i)
+				} else {
+					ranges[i] = b.GetLinePointers(i)
+					cells = append([]Cell(nil), b.GetLine(i)...)
+				}
+				if f, _, _ := CheckRangeCounts(cells); f {
+					// Complete ranges are not revised, so they
+					// are checked here
+					if _, err := checkRange(cells); err != nil {
+						return total + n, errors.Wrapf(err, "%s %d", what, i)
+					}
+					for _, other := range full {
+						if sameRange(other, cells) {
+							return total + n, errors.Errorf("%s %d: duplicate range", what, i)
+						}
+					}
+					full = append(full, cells)
+					if patterns != nil {
+						bits, _ := rangeBits(cells)
+						fullBits[bits] = true
+					}
+					ranges[i] = nil
+				}
+			}
+			for i, r := range ranges {
+				if r == nil {
+					continue // Complete range
+				}
+				k, err := propagateRange(r, full, patterns, fullBits)
+				n += k
+				if err != nil {
+					return total + n, errors.Wrapf(err, "%s %d", what, i)
+				}
+			}
+		}
+		total += n
+		if verbosity > 3 {
+			log.Printf("Propagation pass: %d cell(s) set", n)
+		}
+		if n == 0 {
+			return total, nil
+		}
+	}
+}
+
+// TrySolvePropagate tries to solve the takuzu using the propagation engine.
+// It is a stronger alternative to TrySolveTrivial.
+// It returns true if all cells are defined, and an error if the grid breaks
+// the rules or cannot be completed.
+func (b Takuzu) TrySolvePropagate() (bool, error) {
+	if _, err := b.Propagate(); err != nil {
+		return false, errors.Wrap(err, "the takuzu looks wrong")
+	}
+	full, err := b.Validate()
+	if err != nil {
+		return full, errors.Wrap(err, "the takuzu looks wrong")
+	}
+	return full, nil
+}
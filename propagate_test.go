@@ -0,0 +1,75 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+import "testing"
+
+// allSolutions returns all the solutions of the board
+func allSolutions(t *testing.T, b Takuzu) []Takuzu {
+	t.Helper()
+	solutions := &[]Takuzu{}
+	b.Clone().TrySolveWithOptions(solutions, SolveOptions{})
+	return *solutions
+}
+
+// checkForcedCells reports the defined cells of b that do not match all the
+// solutions
+func checkForcedCells(t *testing.T, name string, b *Takuzu, solutions []Takuzu) {
+	t.Helper()
+	for i := range solutions {
+		if match, l, c := BoardsMatch(b, &solutions[i], true); !match {
+			t.Errorf("%s: cell [%d,%d] does not match the solution %s",
+				name, l, c, solutions[i].ToString())
+			return
+		}
+	}
+}
+
+func TestPropagate(t *testing.T) {
+	for _, b := range testBoards(t) {
+		solutions := allSolutions(t, b)
+		p := b.Clone()
+		_, err := p.Propagate()
+		if len(solutions) == 0 {
+			if err == nil {
+				if _, verr := p.Validate(); verr == nil {
+					t.Errorf("%s: no contradiction found", b.ToString())
+				}
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Propagate: %v", b.ToString(), err)
+			continue
+		}
+		checkForcedCells(t, b.ToString(), &p, solutions)
+
+		full, err := p.TrySolvePropagate()
+		if err != nil {
+			t.Errorf("%s: TrySolvePropagate: %v", b.ToString(), err)
+		}
+		if full && len(solutions) != 1 {
+			t.Errorf("%s: board completed with %d solutions", b.ToString(), len(solutions))
+		}
+	}
+}
+
+func TestPropagateContradictions(t *testing.T) {
+	for _, s := range []string{
+		"111.............", // Adjacent values
+		"00.0............", // Too many zeroes
+		"0.....01.1.1.1..", // The first line is completed with 3 zeroes
+		"01100110........", // Duplicate lines
+		"00..11..11..00..", // Duplicate columns
+	} {
+		b, err := NewFromString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.Propagate(); err == nil {
+			t.Errorf("%s: no error (%s)", s, b.ToString())
+		}
+	}
+}
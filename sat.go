@@ -0,0 +1,457 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+// This file contains a small CDCL SAT solver (conflict-driven clause
+// learning), used by the SAT backend.
+// It uses two watched literals per clause, first-UIP learning, VSIDS
+// variable selection with phase saving, Luby restarts and deletion of the
+// longest learnt clauses.
+
+import "sort"
+
+// satLit is a literal: 2*v for the variable v, 2*v+1 for its negation
+type satLit int
+
+func (l satLit) variable() int { return int(l) >> 1 }
+
+// satValue values
+const (
+	satUndef int8 = iota
+	satTrue
+	satFalse
+)
+
+// satRestartBase is the number of conflicts of the first restart interval
+const satRestartBase = 100
+
+// satMinLearnts is the minimum number of learnt clauses kept by the solver
+const satMinLearnts = 5000
+
+// satStopInterval is the number of conflicts between two calls to the stop
+// function of solve
+const satStopInterval = 256
+
+type satSolver struct {
+	ok         bool       // false if the clauses are unsatisfiable
+	conflicts  int        // Number of conflicts, over all the calls to solve
+	clauses    [][]satLit // Deleted clauses are nil
+	learnts    []int      // Indices of the learnt clauses
+	maxLearnts int
+	watches    [][]int // Clauses watching each literal
+	assigns    []int8  // Values of the variables
+	level      []int
+	reason     []int // Clause that implied each variable, or -1
+	trail      []satLit
+	trailLim   []int
+	qhead      int
+
+	activity []float64
+	varInc   float64
+	phase    []bool // Saved polarity (true for the negative literal)
+	heap     []int  // Variables, ordered by activity
+	heapPos  []int  // Position of the variables in the heap, or -1
+	seen     []bool
+}
+
+func newSATSolver(nVars int) *satSolver {
+	s := &satSolver{
+		ok:         true,
+		maxLearnts: satMinLearnts,
+		watches:    make([][]int, 2*nVars),
+		assigns:    make([]int8, nVars),
+		level:      make([]int, nVars),
+		reason:     make([]int, nVars),
+		activity:   make([]float64, nVars),
+		varInc:     1,
+		phase:      make([]bool, nVars),
+		heapPos:    make([]int, nVars),
+		seen:       make([]bool, nVars),
+	}
+	for v := 0; v < nVars; v++ {
+		s.phase[v] = true
+		s.heapPos[v] = -1
+		s.heapInsert(v)
+	}
+	return s
+}
+
+// value returns the value of a literal
+func (s *satSolver) value(l satLit) int8 {
+	a := s.assigns[l.variable()]
+	if a == satUndef || l&1 == 0 {
+		return a
+	}
+	return satTrue + satFalse - a
+}
+
+func (s *satSolver) decisionLevel() int {
+	return len(s.trailLim)
+}
+
+func (s *satSolver) enqueue(l satLit, from int) {
+	v := l.variable()
+	s.assigns[v] = satTrue
+	if l&1 == 1 {
+		s.assigns[v] = satFalse
+	}
+	s.level[v] = s.decisionLevel()
+	s.reason[v] = from
+	s.trail = append(s.trail, l)
+}
+
+// attach stores a clause (of at least 2 literals) and watches its first two
+// literals
+func (s *satSolver) attach(c []satLit) int {
+	ci := len(s.clauses)
+	s.clauses = append(s.clauses, c)
+	s.watches[c[0]] = append(s.watches[c[0]], ci)
+	s.watches[c[1]] = append(s.watches[c[1]], ci)
+	return ci
+}
+
+// addClause adds a clause; it must be called at decision level 0.
+// It returns false if the clauses are now unsatisfiable.
+func (s *satSolver) addClause(lits []satLit) bool {
+	if !s.ok {
+		return false
+	}
+	c := make([]satLit, 0, len(lits))
+	inClause := make(map[satLit]bool)
+	for _, l := range lits {
+		switch {
+		case s.value(l) == satTrue || inClause[l^1]:
+			return true // Satisfied clause
+		case s.value(l) == satFalse || inClause[l]:
+			continue
+		}
+		inClause[l] = true
+		c = append(c, l)
+	}
+
+	switch len(c) {
+	case 0:
+		s.ok = false
+	case 1:
+		s.enqueue(c[0], -1)
+		s.ok = s.propagate() < 0
+	default:
+		s.attach(c)
+	}
+	return s.ok
+}
+
+// propagate propagates the assignments of the trail.  It returns the
+// index of a conflicting clause, or -1.
+func (s *satSolver) propagate() int {
+	for s.qhead < len(s.trail) {
+		falseLit := s.trail[s.qhead] ^ 1
+		s.qhead++
+
+		ws := s.watches[falseLit]
+		i, j := 0, 0
+		for i < len(ws) {
+			ci := ws[i]
+			i++
+			c := s.clauses[ci]
+			if c == nil {
+				continue // Deleted clause
+			}
+			// The false literal is moved to the second position
+			if c[0] == falseLit {
+				c[0], c[1] = c[1], c[0]
+			}
+			if s.value(c[0]) == satTrue {
+				ws[j] = ci
+				j++
+				continue
+			}
+			// Look for a new literal to watch
+			moved := false
+			for k := 2; k < len(c); k++ {
+				if s.value(c[k]) != satFalse {
+					c[1], c[k] = c[k], c[1]
+					s.watches[c[1]] = append(s.watches[c[1]], ci)
+					moved = true
+					break
+				}
+			}
+			if moved {
+				continue
+			}
+			ws[j] = ci
+			j++
+			if s.value(c[0]) == satFalse {
+				// Conflict
+				for i < len(ws) {
+					ws[j] = ws[i]
+					i++
+					j++
+				}
+				s.watches[falseLit] = ws[:j]
+				return ci
+			}
+			s.enqueue(c[0], ci)
+		}
+		s.watches[falseLit] = ws[:j]
+	}
+	return -1
+}
+
+// analyze computes the first-UIP learnt clause of a conflict and the
+// backtrack level.  The asserting literal is the first one of the clause.
+func (s *satSolver) analyze(confl int) ([]satLit, int) {
+	learnt := []satLit{0}
+	pathCount := 0
+	p := satLit(-1)
+	index := len(s.trail) - 1
+
+	for {
+		c := s.clauses[confl]
+		start := 0
+		if p >= 0 {
+			start = 1 // c[0] is p
+		}
+		for _, q := range c[start:] {
+			v := q.variable()
+			if s.seen[v] || s.level[v] == 0 {
+				continue
+			}
+			s.bumpActivity(v)
+			s.seen[v] = true
+			if s.level[v] >= s.decisionLevel() {
+				pathCount++
+			} else {
+				learnt = append(learnt, q)
+			}
+		}
+		for !s.seen[s.trail[index].variable()] {
+			index--
+		}
+		p = s.trail[index]
+		index--
+		confl = s.reason[p.variable()]
+		s.seen[p.variable()] = false
+		pathCount--
+		if pathCount == 0 {
+			break
+		}
+	}
+	learnt[0] = p ^ 1
+
+	// The literal with the highest level is moved to the second position
+	btLevel := 0
+	for i := 1; i < len(learnt); i++ {
+		s.seen[learnt[i].variable()] = false
+		if lvl := s.level[learnt[i].variable()]; lvl > btLevel {
+			btLevel = lvl
+			learnt[1], learnt[i] = learnt[i], learnt[1]
+		}
+	}
+	return learnt, btLevel
+}
+
+// reduceLearnts deletes the longest half of the learnt clauses.  It must
+// be called at decision level 0, so that no deleted clause is the reason of
+// an assignment that can be analyzed.
+func (s *satSolver) reduceLearnts() {
+	sort.SliceStable(s.learnts, func(i, j int) bool {
+		return len(s.clauses[s.learnts[i]]) < len(s.clauses[s.learnts[j]])
+	})
+	keep := len(s.learnts) / 2
+	for _, ci := range s.learnts[keep:] {
+		if len(s.clauses[ci]) > 2 {
+			s.clauses[ci] = nil
+		} else {
+			s.learnts[keep] = ci
+			keep++
+		}
+	}
+	s.learnts = s.learnts[:keep]
+	s.maxLearnts += s.maxLearnts / 10
+}
+
+// cancelUntil backtracks to the given decision level
+func (s *satSolver) cancelUntil(lvl int) {
+	if s.decisionLevel() <= lvl {
+		return
+	}
+	for i := len(s.trail) - 1; i >= s.trailLim[lvl]; i-- {
+		v := s.trail[i].variable()
+		s.phase[v] = s.trail[i]&1 == 1
+		s.assigns[v] = satUndef
+		s.reason[v] = -1
+		if s.heapPos[v] < 0 {
+			s.heapInsert(v)
+		}
+	}
+	s.trail = s.trail[:s.trailLim[lvl]]
+	s.trailLim = s.trailLim[:lvl]
+	s.qhead = len(s.trail)
+}
+
+func (s *satSolver) bumpActivity(v int) {
+	s.activity[v] += s.varInc
+	if s.activity[v] > 1e100 {
+		for i := range s.activity {
+			s.activity[i] *= 1e-100
+		}
+		s.varInc *= 1e-100
+	}
+	if s.heapPos[v] >= 0 {
+		s.heapUp(s.heapPos[v])
+	}
+}
+
+// pickBranch returns the unassigned variable with the highest activity, or
+// -1 if all the variables are assigned
+func (s *satSolver) pickBranch() int {
+	for len(s.heap) > 0 {
+		v := s.heapPop()
+		if s.assigns[v] == satUndef {
+			return v
+		}
+	}
+	return -1
+}
+
+// solve looks for a model of the clauses.  The stop function is called
+// regularly; the search is aborted if it returns an error.
+// When it returns true, the model is available in s.assigns until the next
+// call to cancelUntil.
+func (s *satSolver) solve(stop func() error) (bool, error) {
+	if !s.ok {
+		return false, nil
+	}
+	s.cancelUntil(0)
+	if s.propagate() >= 0 {
+		s.ok = false
+		return false, nil
+	}
+
+	var restarts, sinceRestart int
+	for {
+		confl := s.propagate()
+		if confl >= 0 {
+			s.conflicts++
+			sinceRestart++
+			if s.decisionLevel() == 0 {
+				s.ok = false
+				return false, nil
+			}
+			learnt, btLevel := s.analyze(confl)
+			s.cancelUntil(btLevel)
+			if len(learnt) == 1 {
+				s.enqueue(learnt[0], -1)
+			} else {
+				ci := s.attach(learnt)
+				s.learnts = append(s.learnts, ci)
+				s.enqueue(learnt[0], ci)
+			}
+			s.varInc /= 0.95
+
+			if s.conflicts%satStopInterval == 0 {
+				if err := stop(); err != nil {
+					s.cancelUntil(0)
+					return false, err
+				}
+			}
+			if sinceRestart >= satRestartBase*luby(restarts) {
+				s.cancelUntil(0)
+				restarts++
+				sinceRestart = 0
+				if len(s.learnts) > s.maxLearnts {
+					s.reduceLearnts()
+				}
+			}
+			continue
+		}
+
+		v := s.pickBranch()
+		if v < 0 {
+			return true, nil
+		}
+		s.trailLim = append(s.trailLim, len(s.trail))
+		l := satLit(2 * v)
+		if s.phase[v] {
+			l++
+		}
+		s.enqueue(l, -1)
+	}
+}
+
+// luby returns the i-th element (from 0) of the Luby sequence
+// 1 1 2 1 1 2 4 1 1 2 ...
+func luby(i int) int {
+	size, seq := 1, 0
+	for size < i+1 {
+		seq++
+		size = 2*size + 1
+	}
+	for size-1 != i {
+		size = (size - 1) >> 1
+		seq--
+		i = i % size
+	}
+	return 1 << uint(seq)
+}
+
+// Binary heap of the variables, ordered by decreasing activity
+
+func (s *satSolver) heapLess(i, j int) bool {
+	return s.activity[s.heap[i]] > s.activity[s.heap[j]]
+}
+
+func (s *satSolver) heapSwap(i, j int) {
+	s.heap[i], s.heap[j] = s.heap[j], s.heap[i]
+	s.heapPos[s.heap[i]] = i
+	s.heapPos[s.heap[j]] = j
+}
+
+func (s *satSolver) heapUp(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !s.heapLess(i, parent) {
+			break
+		}
+		s.heapSwap(i, parent)
+		i = parent
+	}
+}
+
+func (s *satSolver) heapDown(i int) {
+	for {
+		child := 2*i + 1
+		if child >= len(s.heap) {
+			return
+		}
+		if child+1 < len(s.heap) && s.heapLess(child+1, child) {
+			child++
+		}
+		if !s.heapLess(child, i) {
+			return
+		}
+		s.heapSwap(i, child)
+		i = child
+	}
+}
+
+func (s *satSolver) heapInsert(v int) {
+	s.heap = append(s.heap, v)
+	s.heapPos[v] = len(s.heap) - 1
+	s.heapUp(len(s.heap) - 1)
+}
+
+func (s *satSolver) heapPop() int {
+	v := s.heap[0]
+	last := len(s.heap) - 1
+	s.heapSwap(0, last)
+	s.heap = s.heap[:last]
+	s.heapPos[v] = -1
+	if last > 0 {
+		s.heapDown(0)
+	}
+	return v
+}
@@ -7,6 +7,7 @@ package takuzu
 // This file contains the methods used to solve a takuzu puzzle.
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"runtime"
@@ -27,16 +28,22 @@ func SetVerbosityLevel(level int) {
 
 // SetSchrodingerLevel initializes the "Schrödinger" level (0 means disabled)
 // It must be called before any board generation or reduction.
+// Up to this recursion level, both values of a cell are explored
+// concurrently, with at most GOMAXPROCS cells being explored at the same
+// time by a resolution.
 func SetSchrodingerLevel(level uint) {
 	schrodLvl = level
 }
 
-func (b Takuzu) guessPos(l, c int) int {
+// guessPos returns the value of the cell if it can be guessed using its
+// line and its column, or -1.  The board bx is used as a scratch buffer and
+// must have the same size.
+func (b Takuzu) guessPos(l, c int, bx Takuzu) int {
 	if b.Board[l][c].Defined {
 		return b.Board[l][c].Value
 	}
 
-	bx := b.Clone()
+	Copy(&b, &bx)
 	bx.Set(l, c, 0)
 	bx.FillLineColumn(l, c)
 	if bx.CheckLine(l) != nil || bx.CheckColumn(c) != nil {
@@ -56,12 +63,13 @@ func (b Takuzu) guessPos(l, c int) int {
 // can be guessed using trivial methods.
 // It returns {-1, -1, -1} if none can be found.
 func (b Takuzu) TrivialHint() (line, col, value int) {
+	bx := New(b.Size)
 	for line = 0; line < b.Size; line++ {
 		for col = 0; col < b.Size; col++ {
 			if b.Board[line][col].Defined {
 				continue
 			}
-			if value = b.guessPos(line, col); value != -1 {
+			if value = b.guessPos(line, col, bx); value != -1 {
 				return
 			}
 		}
@@ -72,13 +80,13 @@ func (b Takuzu) TrivialHint() (line, col, value int) {
 
 // trySolveTrivialPass does 1 pass over the takuzu board and tries to find
 // values using simple guesses.
-func (b Takuzu) trySolveTrivialPass() (changed bool) {
+func (b Takuzu) trySolveTrivialPass(bx Takuzu) (changed bool) {
 	for line := 0; line < b.Size; line++ {
 		for col := 0; col < b.Size; col++ {
 			if b.Board[line][col].Defined {
 				continue
 			}
-			if guess := b.guessPos(line, col); guess != -1 {
+			if guess := b.guessPos(line, col, bx); guess != -1 {
 				b.Set(line, col, guess)
 				if verbosity > 3 {
 					log.Printf("Trivial: Setting [%d,%d] to %d", line, col, guess)
@@ -93,8 +101,13 @@ func (b Takuzu) trySolveTrivialPass() (changed bool) {
 // TrySolveTrivial tries to solve the takuzu using a loop over simple methods
 // It returns true if all cells are defined, and an error if the grid breaks the rules.
 func (b Takuzu) TrySolveTrivial() (bool, error) {
+	return b.trySolveTrivial(New(b.Size))
+}
+
+// trySolveTrivial is TrySolveTrivial, using bx as a scratch buffer
+func (b Takuzu) trySolveTrivial(bx Takuzu) (bool, error) {
 	for {
-		changed := b.trySolveTrivialPass()
+		changed := b.trySolveTrivialPass(bx)
 		if verbosity > 3 {
 			status := "stuck"
 			if changed {
@@ -118,9 +131,75 @@ func (b Takuzu) TrySolveTrivial() (bool, error) {
 	return full, nil
 }
 
+// boardStack is a stack of preallocated boards, one per recursion level
+// from the base level, used by a resolution goroutine to avoid cloning
+// boards for each guess
+type boardStack struct {
+	size    int
+	base    int
+	scratch Takuzu // Scratch buffer for the trivial resolution
+	boards  []Takuzu
+}
+
+func newBoardStack(size, base int) *boardStack {
+	return &boardStack{size: size, base: base, scratch: New(size)}
+}
+
+// load copies the board t to the buffer of the given level and returns it
+func (s *boardStack) load(level int, t Takuzu) Takuzu {
+	for len(s.boards) <= level-s.base {
+		s.boards = append(s.boards, New(s.size))
+	}
+	b := s.boards[level-s.base]
+	Copy(&t, &b)
+	return b
+}
+
+// errSolutionLimit is used to stop the search when the maximum number of
+// solutions has been found
+var errSolutionLimit = errors.New("solution limit reached")
+
+// SolveOptions contains the options of the recursive solver
+type SolveOptions struct {
+	// Timeout is the resolution timeout (0 means no timeout)
+	Timeout time.Duration
+	// MaxSolutions stops the search for all the solutions once this
+	// number of solutions has been found (0 means no limit)
+	MaxSolutions int
+	// Context can be used to cancel the resolution
+	Context context.Context
+	// Propagate enables the propagation engine instead of the trivial
+	// methods between guesses
+	Propagate bool
+	// Heuristic is the method used to select the cells to guess
+	Heuristic Heuristic
+}
+
+// isAbort returns true if the error should stop the whole resolution
+func isAbort(err error) bool {
+	switch errors.Cause(err) {
+	case errSolutionLimit, context.Canceled, context.DeadlineExceeded:
+		return true
+	}
+	return errors.Cause(err).Error() == "timeout"
+}
+
 // TrySolveRecurse tries to solve the takuzu recursively, using trivial
 // method first and using guesses if it fails.
 func (b Takuzu) TrySolveRecurse(allSolutions *[]Takuzu, timeout time.Duration) (*Takuzu, error) {
+	return b.TrySolveWithOptions(allSolutions, SolveOptions{Timeout: timeout})
+}
+
+// TrySolveWithOptions tries to solve the takuzu recursively, like
+// TrySolveRecurse.  If the search is stopped because MaxSolutions have been
+// found, no error is returned.  If the context is canceled, the context
+// error is returned.
+func (b Takuzu) TrySolveWithOptions(allSolutions *[]Takuzu, opts SolveOptions) (*Takuzu, error) {
+	timeout := opts.Timeout
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
 
 	var solutionsMux sync.Mutex
 	var singleSolution *Takuzu
@@ -136,9 +215,38 @@ func (b Takuzu) TrySolveRecurse(allSolutions *[]Takuzu, timeout time.Duration) (
 
 	startTime := time.Now()
 
-	var recurseSolve func(level int, t Takuzu, errStatus chan<- error) error
+	// addSolution records a solution; it returns errSolutionLimit when
+	// the maximum number of solutions has been reached.
+	addSolution := func(t *Takuzu) error {
+		solutionsMux.Lock()
+		defer solutionsMux.Unlock()
+		// The board buffer will be reused
+		sol := t.Clone()
+		singleSolution = &sol
+		if globalSearch {
+			solutionMap[sol.ToString()] = &sol
+			if opts.MaxSolutions > 0 && len(solutionMap) >= opts.MaxSolutions {
+				return errSolutionLimit
+			}
+		}
+		return nil
+	}
+
+	// In Schrödinger mode, at most GOMAXPROCS cells are explored
+	// concurrently; the other cells are explored sequentially.
+	schrodSlots := make(chan struct{}, runtime.GOMAXPROCS(0))
+
+	releaseSlot := func(schrodinger bool) {
+		if schrodinger {
+			<-schrodSlots
+		}
+	}
+
+	var recurseSolve func(ctx context.Context, level int, t Takuzu, stack *boardStack, errStatus chan<- error) error
 
-	recurseSolve = func(level int, t Takuzu, errStatus chan<- error) error {
+	// The board t is updated; the boards of the next levels are taken from
+	// the stack.  A new stack is used by the concurrent goroutines.
+	recurseSolve = func(ctx context.Context, level int, t Takuzu, stack *boardStack, errStatus chan<- error) error {
 
 		reportStatus := func(failure error) {
 			// Report status to the caller's channel
@@ -147,21 +255,24 @@ func (b Takuzu) TrySolveRecurse(allSolutions *[]Takuzu, timeout time.Duration) (
 			}
 		}
 
-		// In Schröndinger mode we check concurrently both values for a cell
-		var schrodinger bool
-		concurrentRoutines := 1
-		if level < int(schrodLvl) {
-			schrodinger = true
-			concurrentRoutines = 2
-		}
-
 		var status [2]chan error
 		status[0] = make(chan error)
 		status[1] = make(chan error)
 
 		for {
+			if err := ctx.Err(); err != nil {
+				reportStatus(err)
+				return err
+			}
+
 			// Try simple resolution first
-			full, err := t.TrySolveTrivial()
+			var full bool
+			var err error
+			if opts.Propagate {
+				full, err = t.TrySolvePropagate()
+			} else {
+				full, err = t.trySolveTrivial(stack.scratch)
+			}
 			if err != nil {
 				reportStatus(err)
 				return err
@@ -171,13 +282,10 @@ func (b Takuzu) TrySolveRecurse(allSolutions *[]Takuzu, timeout time.Duration) (
 				if verbosity > 1 {
 					log.Printf("{%d} The takuzu is correct and complete.", level)
 				}
-				solutionsMux.Lock()
-				singleSolution = &t
-				if globalSearch {
-					solutionMap[t.ToString()] = &t
+				if err := addSolution(&t); err != nil {
+					reportStatus(err)
+					return err
 				}
-				solutionsMux.Unlock()
-
 				reportStatus(nil)
 				return nil
 			}
@@ -190,18 +298,9 @@ func (b Takuzu) TrySolveRecurse(allSolutions *[]Takuzu, timeout time.Duration) (
 
 			changed := false
 
-			// Looking for first empty cell
-			var line, col int
-		firstClear:
-			for line = 0; line < t.Size; line++ {
-				for col = 0; col < t.Size; col++ {
-					if !t.Board[line][col].Defined {
-						break firstClear
-					}
-				}
-			}
-
-			if line == t.Size || col == t.Size {
+			// Select the cell to guess
+			line, col, ok := t.selectCell(opts.Heuristic)
+			if !ok {
 				break
 			}
 
@@ -209,6 +308,30 @@ func (b Takuzu) TrySolveRecurse(allSolutions *[]Takuzu, timeout time.Duration) (
 				log.Printf("{%d} GUESS - Trying values for [%d,%d]", level, line, col)
 			}
 
+			// In Schrödinger mode we check concurrently both values for
+			// a cell, if a slot is available.  The branches get their own
+			// context, so that a branch can be canceled once the result
+			// of its sibling is known.
+			var schrodinger bool
+			concurrentRoutines := 1
+			branchCtx, cancelBranches := ctx, context.CancelFunc(func() {})
+			if level < int(schrodLvl) {
+				select {
+				case schrodSlots <- struct{}{}:
+					schrodinger = true
+					concurrentRoutines = 2
+					branchCtx, cancelBranches = context.WithCancel(ctx)
+				default:
+				}
+			}
+			// stopSibling cancels the other branch and waits for it
+			stopSibling := func(i, val int) {
+				cancelBranches()
+				if i+1 < concurrentRoutines {
+					<-status[1-val]
+				}
+			}
+
 			var val int
 			err = nil
 			errCount := 0
@@ -222,9 +345,13 @@ func (b Takuzu) TrySolveRecurse(allSolutions *[]Takuzu, timeout time.Duration) (
 				// Launch goroutines for cell values of 0 and/or 1
 				for testCase := 0; testCase < 2; testCase++ {
 					if schrodinger || testval == testCase {
-						tx := t.Clone()
+						st := stack
+						if schrodinger && testCase == 1 {
+							st = newBoardStack(t.Size, level+1)
+						}
+						tx := st.load(level+1, t)
 						tx.Set(line, col, testCase)
-						go recurseSolve(level+1, tx, status[testCase])
+						go recurseSolve(branchCtx, level+1, tx, st, status[testCase])
 					}
 				}
 
@@ -248,42 +375,35 @@ func (b Takuzu) TrySolveRecurse(allSolutions *[]Takuzu, timeout time.Duration) (
 
 					if err == nil {
 						if !globalSearch {
+							stopSibling(i, val)
+							releaseSlot(schrodinger)
 							reportStatus(nil)
-							if i+1 < concurrentRoutines {
-								// Schröndinger mode and we still have one status to fetch
-								<-status[1-val]
-							}
 							return nil
 						}
 						continue
 					}
 					if timeout > 0 && level > 2 && time.Since(startTime) > timeout {
-						if errors.Cause(err).Error() != "timeout" {
+						if !isAbort(err) {
 							if verbosity > 0 {
 								log.Printf("{%d} Timeout, giving up", level)
 							}
 							err := errors.New("timeout")
+							stopSibling(i, val)
+							releaseSlot(schrodinger)
 							reportStatus(err)
-							if i+1 < concurrentRoutines {
-								// Schröndinger mode and we still have one status to fetch
-								<-status[1-val]
-							}
-							// XXX actually can't close the channel and leave, can I?
 							return err
 						}
 					}
 
 					// err != nil: we can set a value --  unless this was a timeout
-					if errors.Cause(err).Error() == "timeout" {
+					// or the search has been stopped
+					if isAbort(err) {
 						if verbosity > 1 {
-							log.Printf("{%d} Timeout propagation", level)
+							log.Printf("{%d} Abort propagation (%v)", level, err)
 						}
+						stopSibling(i, val)
+						releaseSlot(schrodinger)
 						reportStatus(err)
-						if i+1 < concurrentRoutines {
-							// Schröndinger mode and we still have one status to fetch
-							<-status[1-val]
-						}
-						// XXX actually can't close the channel and leave, can I?
 						return err
 					}
 
@@ -304,6 +424,8 @@ func (b Takuzu) TrySolveRecurse(allSolutions *[]Takuzu, timeout time.Duration) (
 					break
 				}
 			}
+			cancelBranches()
+			releaseSlot(schrodinger)
 
 			if verbosity > 2 {
 				log.Printf("{%d} End of cycle.\n\n", level)
@@ -328,9 +450,6 @@ func (b Takuzu) TrySolveRecurse(allSolutions *[]Takuzu, timeout time.Duration) (
 			}
 		}
 
-		// Try to force garbage collection
-		runtime.GC()
-
 		full, err := t.Validate()
 		if err != nil {
 			if verbosity > 1 {
@@ -344,12 +463,10 @@ func (b Takuzu) TrySolveRecurse(allSolutions *[]Takuzu, timeout time.Duration) (
 			if verbosity > 1 {
 				log.Println("The takuzu is correct and complete")
 			}
-			solutionsMux.Lock()
-			singleSolution = &t
-			if globalSearch {
-				solutionMap[t.ToString()] = &t
+			if err := addSolution(&t); err != nil {
+				reportStatus(err)
+				return err
 			}
-			solutionsMux.Unlock()
 		}
 
 		reportStatus(nil)
@@ -357,7 +474,7 @@ func (b Takuzu) TrySolveRecurse(allSolutions *[]Takuzu, timeout time.Duration) (
 	}
 
 	status := make(chan error)
-	go recurseSolve(0, b, status)
+	go recurseSolve(ctx, 0, b, newBoardStack(b.Size, 1), status)
 
 	err := <-status // Wait for it...
 
@@ -368,7 +485,7 @@ func (b Takuzu) TrySolveRecurse(allSolutions *[]Takuzu, timeout time.Duration) (
 		}
 	}
 
-	if err != nil {
+	if err != nil && errors.Cause(err) != errSolutionLimit {
 		return firstSol, err
 	}
 
@@ -0,0 +1,37 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+import (
+	"fmt"
+	"testing"
+)
+
+// BenchmarkSchrodinger measures the speedup of the concurrent exploration
+// of the first recursion levels
+func BenchmarkSchrodinger(b *testing.B) {
+	tak := benchmarkBoard(b)
+	defer SetSchrodingerLevel(schrodLvl)
+	for _, level := range []uint{0, 1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("level=%d", level), func(b *testing.B) {
+			SetSchrodingerLevel(level)
+			for i := 0; i < b.N; i++ {
+				allSol := &[]Takuzu{}
+				tak.Clone().TrySolveWithOptions(allSol, SolveOptions{})
+			}
+		})
+	}
+}
+
+// BenchmarkSolveRecurseAllocs reports the allocations of the recursive
+// solver, which reuses the boards of each recursion level
+func BenchmarkSolveRecurseAllocs(b *testing.B) {
+	tak := benchmarkBoard(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tak.Clone().TrySolveWithOptions(nil, SolveOptions{})
+	}
+}
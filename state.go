@@ -0,0 +1,258 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+// This file contains the incremental state of a board.
+// The bitmasks of the 0s and of the 1s of each line and column are updated
+// when a cell is set, so that the validity of the board can be checked
+// without rescanning the lines and the columns.
+
+import (
+	"math/bits"
+
+	"github.com/pkg/errors"
+)
+
+// MaxBoardStateSize is the largest board size supported by BoardState
+const MaxBoardStateSize = 64
+
+// BoardState is an incremental state attached to a board.  It keeps the
+// counts of 0s and 1s, the runs of identical values and the completeness
+// of every line and column up to date as the cells are set with its Set
+// method.  If the board is modified directly, Reset must be called.
+type BoardState struct {
+	board *Takuzu
+	half  int
+	mask  uint64 // Bitmask of the cells of a range
+
+	// Ranges: lines are 0..size-1, columns are size..2*size-1
+	vals [2][]uint64       // Bitmasks of the 0s and of the 1s of each range
+	full [2]map[uint64]int // Complete lines and complete columns
+	bad  []bool            // Ranges breaking the balance or adjacency rules
+
+	empty  int // Number of empty cells
+	broken int // Number of ranges breaking the balance or adjacency rules
+	dups   int // Number of complete ranges identical to a previous one
+}
+
+// NewBoardState creates the incremental state of the board b
+func NewBoardState(b *Takuzu) (*BoardState, error) {
+	if b.Size > MaxBoardStateSize {
+		return nil, errors.Errorf("unsupported board size %d", b.Size)
+	}
+	s := &BoardState{board: b}
+	s.Reset()
+	return s, nil
+}
+
+// Board returns the board of the state
+func (s *BoardState) Board() *Takuzu {
+	return s.board
+}
+
+// Reset rebuilds the state from the current content of the board
+func (s *BoardState) Reset() {
+	size := s.board.Size
+	s.half = size / 2
+	s.mask = 1<<uint(size) - 1
+	if size == 64 {
+		s.mask = ^uint64(0)
+	}
+	for v := 0; v < 2; v++ {
+		s.vals[v] = make([]uint64, 2*size)
+		s.full[v] = make(map[uint64]int)
+	}
+	s.bad = make([]bool, 2*size)
+	s.empty, s.broken, s.dups = size*size, 0, 0
+
+	for l := range s.board.Board {
+		for c, cell := range s.board.Board[l] {
+			if cell.Defined {
+				s.update(l, c, -1, cell.Value)
+			}
+		}
+	}
+}
+
+// Set sets the value of a cell of the board and updates the state.
+// A value -1 will undefine the cell.
+func (s *BoardState) Set(l, c, value int) {
+	old := -1
+	if cell := s.board.Board[l][c]; cell.Defined {
+		old = cell.Value
+	}
+	s.board.Set(l, c, value)
+	if value != 0 && value != 1 {
+		value = -1
+	}
+	if value != old {
+		s.update(l, c, old, value)
+	}
+}
+
+// update updates the line l and the column c when the value of the cell
+// changes from old to value (-1 for an empty cell)
+func (s *BoardState) update(l, c, old, value int) {
+	size := s.board.Size
+	switch {
+	case old < 0:
+		s.empty--
+	case value < 0:
+		s.empty++
+	}
+
+	for _, rk := range [2][2]int{{l, c}, {size + c, l}} {
+		r, k := rk[0], rk[1]
+		o := r / size
+		if s.rangeFull(r) {
+			if s.full[o][s.vals[1][r]]--; s.full[o][s.vals[1][r]] > 0 {
+				s.dups--
+			} else {
+				delete(s.full[o], s.vals[1][r])
+			}
+		}
+		if old >= 0 {
+			s.vals[old][r] &^= 1 << uint(k)
+		}
+		if value >= 0 {
+			s.vals[value][r] |= 1 << uint(k)
+		}
+		if s.rangeFull(r) {
+			if s.full[o][s.vals[1][r]]++; s.full[o][s.vals[1][r]] > 1 {
+				s.dups++
+			}
+		}
+
+		bad := s.rangeError(r) != nil
+		if bad != s.bad[r] {
+			if bad {
+				s.broken++
+			} else {
+				s.broken--
+			}
+			s.bad[r] = bad
+		}
+	}
+}
+
+// rangeFull returns true if all the cells of the range r are defined
+func (s *BoardState) rangeFull(r int) bool {
+	return s.vals[0][r]|s.vals[1][r] == s.mask
+}
+
+// rangeError returns an error if the range r breaks the balance or the
+// adjacency rules.  The error is the same as the one of checkRange.
+func (s *BoardState) rangeError(r int) error {
+	// The first run of 3 identical values is reported
+	first, value := 64, 0
+	for v := 0; v < 2; v++ {
+		m := s.vals[v][r]
+		if t := m & (m >> 1) & (m >> 2); t != 0 && bits.TrailingZeros64(t) < first {
+			first, value = bits.TrailingZeros64(t), v
+		}
+	}
+	if first < 64 {
+		return validationError{
+			ErrorType: ErrorTooManyAdjacentValues,
+			CellValue: &value,
+		}
+	}
+	for v := 0; v < 2; v++ {
+		if bits.OnesCount64(s.vals[v][r]) > s.half {
+			v := v
+			return validationError{
+				ErrorType: ErrorTooManyValues,
+				CellValue: &v,
+			}
+		}
+	}
+	return nil
+}
+
+// LineCounts returns true if all cells of the line i are defined, as well
+// as the number of 0s and the number of 1s of the line.
+func (s *BoardState) LineCounts(i int) (full bool, n0, n1 int) {
+	return s.rangeFull(i), bits.OnesCount64(s.vals[0][i]), bits.OnesCount64(s.vals[1][i])
+}
+
+// ColumnCounts returns true if all cells of the column i are defined, as
+// well as the number of 0s and the number of 1s of the column.
+func (s *BoardState) ColumnCounts(i int) (full bool, n0, n1 int) {
+	return s.LineCounts(s.board.Size + i)
+}
+
+// CheckLine returns an error if the line i fails validation
+func (s *BoardState) CheckLine(i int) error {
+	err := s.rangeError(i)
+	if err != nil {
+		err := err.(validationError)
+		err.LineNumber = &i
+		return err
+	}
+	return nil
+}
+
+// CheckColumn returns an error if the column i fails validation
+func (s *BoardState) CheckColumn(i int) error {
+	err := s.rangeError(s.board.Size + i)
+	if err != nil {
+		err := err.(validationError)
+		err.ColumnNumber = &i
+		return err
+	}
+	return nil
+}
+
+// Full returns true if all cells are defined
+func (s *BoardState) Full() bool {
+	return s.empty == 0
+}
+
+// Valid returns true if the board follows the rules (not completeness)
+func (s *BoardState) Valid() bool {
+	return s.broken == 0 && s.dups == 0
+}
+
+// Validate checks the board for errors (not completeness), like the
+// Validate method of the board.  The lines and columns are only scanned to
+// report an error.
+// Returns true if all cells are defined.
+func (s *BoardState) Validate() (bool, error) {
+	if s.Valid() {
+		return s.Full(), nil
+	}
+
+	size := s.board.Size
+	lineVals := make(map[uint64]bool)
+	colVals := make(map[uint64]bool)
+	for i := 0; i < size; i++ {
+		if err := s.CheckLine(i); err != nil {
+			return false, err
+		}
+		if s.rangeFull(i) {
+			if lineVals[s.vals[1][i]] {
+				return false, validationError{
+					ErrorType:  ErrorDuplicate,
+					LineNumber: &i,
+				}
+			}
+			lineVals[s.vals[1][i]] = true
+		}
+
+		if err := s.CheckColumn(i); err != nil {
+			return false, err
+		}
+		if s.rangeFull(size + i) {
+			if colVals[s.vals[1][size+i]] {
+				return false, validationError{
+					ErrorType:    ErrorDuplicate,
+					ColumnNumber: &i,
+				}
+			}
+			colVals[s.vals[1][size+i]] = true
+		}
+	}
+	return false, errors.New("internal validation error")
+}
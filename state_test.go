@@ -0,0 +1,106 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+)
+
+// checkBoardState compares the incremental state with a full validation of
+// the board
+func checkBoardState(t *testing.T, s *BoardState, step int) bool {
+	t.Helper()
+	b := s.Board()
+	full, err := b.Validate()
+	sFull, sErr := s.Validate()
+	if s.Valid() != (err == nil) || sFull != full || (sErr == nil) != (err == nil) {
+		t.Errorf("step %d: %s: state (%v, %v), Validate (%v, %v)",
+			step, b.ToString(), sFull, sErr, full, err)
+		return false
+	}
+	if s.Full() == containsEmpty(b) {
+		t.Errorf("step %d: %s: Full returned %v", step, b.ToString(), s.Full())
+		return false
+	}
+	for i := 0; i < b.Size; i++ {
+		lFull, l0, l1 := s.LineCounts(i)
+		if f, n0, n1 := CheckRangeCounts(b.GetLine(i)); f != lFull || n0 != l0 || n1 != l1 {
+			t.Errorf("step %d: %s: line %d counts", step, b.ToString(), i)
+			return false
+		}
+		cFull, c0, c1 := s.ColumnCounts(i)
+		if f, n0, n1 := CheckRangeCounts(b.GetColumn(i)); f != cFull || n0 != c0 || n1 != c1 {
+			t.Errorf("step %d: %s: column %d counts", step, b.ToString(), i)
+			return false
+		}
+		if (s.CheckLine(i) == nil) != (checkRangeErr(b.GetLine(i)) == nil) {
+			t.Errorf("step %d: %s: line %d check", step, b.ToString(), i)
+			return false
+		}
+		if (s.CheckColumn(i) == nil) != (checkRangeErr(b.GetColumn(i)) == nil) {
+			t.Errorf("step %d: %s: column %d check", step, b.ToString(), i)
+			return false
+		}
+	}
+	return true
+}
+
+// containsEmpty returns true if a cell of the board is not defined
+func containsEmpty(b *Takuzu) bool {
+	for l := range b.Board {
+		for _, c := range b.Board[l] {
+			if !c.Defined {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkRangeErr returns the rule error of a range
+func checkRangeErr(cells []Cell) error {
+	_, err := checkRange(cells)
+	return err
+}
+
+func TestBoardStateMatchesValidate(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, size := range []int{4, 6, 8} {
+		// Starting from a solution gives complete and duplicate ranges
+		sol := trailRandomSolution(context.Background(), size, rng)
+		for run := 0; run < 20; run++ {
+			b := New(size)
+			if run%2 == 1 {
+				b = sol.Clone()
+			}
+			s, err := NewBoardState(&b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !checkBoardState(t, s, 0) {
+				continue
+			}
+			for step := 1; step <= 200; step++ {
+				// Set or clear a random cell
+				l, c := rng.Intn(size), rng.Intn(size)
+				s.Set(l, c, rng.Intn(3)-1)
+				if !checkBoardState(t, s, step) {
+					break
+				}
+			}
+
+			// Reset after a direct modification
+			b.Set(0, 0, rng.Intn(2))
+			s.Reset()
+			checkBoardState(t, s, -1)
+		}
+	}
+
+	if _, err := NewBoardState(&Takuzu{Size: MaxBoardStateSize + 2}); err == nil {
+		t.Error("no error for a large board")
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+// This file contains the functions related to board symmetries.
+//
+// The takuzu rules are invariant under the 8 symmetries of the square
+// (rotations and reflections) and under the exchange of 0s and 1s, so
+// boards that only differ by such a transformation are equivalent puzzles.
+
+// Transpose returns a copy of the board with lines and columns swapped
+func (b Takuzu) Transpose() Takuzu {
+	t := New(b.Size)
+	for l := range b.Board {
+		for c := range b.Board[l] {
+			t.Board[c][l] = b.Board[l][c]
+		}
+	}
+	return t
+}
+
+// Rotate returns a copy of the board rotated by 90 degrees clockwise
+func (b Takuzu) Rotate() Takuzu {
+	t := New(b.Size)
+	n := b.Size
+	for l := range b.Board {
+		for c := range b.Board[l] {
+			t.Board[c][n-1-l] = b.Board[l][c]
+		}
+	}
+	return t
+}
+
+// Invert returns a copy of the board with 0s and 1s exchanged
+func (b Takuzu) Invert() Takuzu {
+	t := b.Clone()
+	for l := range t.Board {
+		for c := range t.Board[l] {
+			if t.Board[l][c].Defined {
+				t.Board[l][c].Value = 1 - t.Board[l][c].Value
+			}
+		}
+	}
+	return t
+}
+
+// CanonicalString returns the canonical string representation of the board,
+// i.e. the smallest string representation of all the equivalent boards.
+// Two boards are equivalent if and only if their canonical strings match.
+func (b Takuzu) CanonicalString() string {
+	var best string
+	t := b.Clone()
+	for i := 0; i < 4; i++ {
+		for _, v := range []Takuzu{t, t.Transpose()} {
+			for _, x := range []Takuzu{v, v.Invert()} {
+				if s := x.ToString(); best == "" || s < best {
+					best = s
+				}
+			}
+		}
+		t = t.Rotate()
+	}
+	return best
+}
@@ -20,6 +20,12 @@ type Cell struct {
 	Value   int
 }
 
+// Position is the position of a cell on a board
+type Position struct {
+	Line int `json:"line"`
+	Col  int `json:"col"`
+}
+
 // Takuzu is a Takuzu game board (Size x Size)
 type Takuzu struct {
 	Size  int
@@ -129,18 +135,7 @@ func BoardsMatch(t1, t2 *Takuzu, ignoreUndefined bool) (match bool, line, col in
 
 	for line = range t1.Board {
 		for col = range t1.Board[line] {
-			if !t1.Board[line][col].Defined || !t2.Board[line][col].Defined {
-				// At least one of the cells is empty
-				if ignoreUndefined ||
-					!(t1.Board[line][col].Defined || t2.Board[line][col].Defined) {
-					// Both cells are empty or we ignore empty cells
-					continue
-				}
-				match = false
-				return
-			}
-			// Both cells are defined
-			if t1.Board[line][col].Value != t2.Board[line][col].Value {
+			if !cellsMatch(t1.Board[line][col], t2.Board[line][col], ignoreUndefined) {
 				match = false
 				return
 			}
@@ -151,6 +146,32 @@ func BoardsMatch(t1, t2 *Takuzu, ignoreUndefined bool) (match bool, line, col in
 	return
 }
 
+// cellsMatch compares two cells, optionally ignoring empty cells
+func cellsMatch(c1, c2 Cell, ignoreUndefined bool) bool {
+	if !c1.Defined || !c2.Defined {
+		// At least one of the cells is empty
+		// Both cells must be empty unless we ignore empty cells
+		return ignoreUndefined || !(c1.Defined || c2.Defined)
+	}
+	// Both cells are defined
+	return c1.Value == c2.Value
+}
+
+// BoardsDiff compares a Takuzu board to another, optionally ignoring
+// empty cells.  It returns the positions of the cells that do not match,
+// in row-major order.  The boards must have the same size.
+func BoardsDiff(t1, t2 *Takuzu, ignoreUndefined bool) []Position {
+	var diff []Position
+	for line := range t1.Board {
+		for col := range t1.Board[line] {
+			if !cellsMatch(t1.Board[line][col], t2.Board[line][col], ignoreUndefined) {
+				diff = append(diff, Position{Line: line, Col: col})
+			}
+		}
+	}
+	return diff
+}
+
 // Set sets the value of the cell; a value -1 will set the cell as undefined
 func (c *Cell) Set(value int) {
 	if value != 0 && value != 1 {
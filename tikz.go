@@ -0,0 +1,84 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+// This file contains the LaTeX/TikZ writer.
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+)
+
+// DefaultTikZCellSize is the default TikZ cell size, in centimeters
+const DefaultTikZCellSize = 0.8
+
+// TikZOptions contains the options of the TikZ writer
+type TikZOptions struct {
+	// Standalone makes the writer emit a complete LaTeX document,
+	// using the standalone document class.
+	Standalone bool
+	// CellSize is the cell size in centimeters; the default is used if 0
+	CellSize float64
+	// Solution is the optional solution of the board.  If it is set, the
+	// cells that are not defined on the board are filled with a lighter
+	// colour.
+	Solution *Takuzu
+	// Highlight is a list of cells to highlight
+	Highlight []Position
+}
+
+// WriteTikZ writes the board as a TikZ picture
+func (b Takuzu) WriteTikZ(w io.Writer, opts TikZOptions) error {
+	cs := opts.CellSize
+	if cs <= 0 {
+		cs = DefaultTikZCellSize
+	}
+	size := strconv.Itoa(b.Size)
+	f := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	bw := bufio.NewWriter(w)
+
+	if opts.Standalone {
+		bw.WriteString("\\documentclass[tikz,border=2mm]{standalone}\n")
+		bw.WriteString("\\begin{document}\n")
+	}
+
+	bw.WriteString("\\begin{tikzpicture}[x=" + f(cs) + "cm,y=-" + f(cs) + "cm]\n")
+	for _, p := range opts.Highlight {
+		if p.Line < 0 || p.Line >= b.Size || p.Col < 0 || p.Col >= b.Size {
+			continue
+		}
+		bw.WriteString("  \\fill[yellow!40] (" + strconv.Itoa(p.Col) + "," +
+			strconv.Itoa(p.Line) + ") rectangle ++(1,1);\n")
+	}
+	bw.WriteString("  \\draw[step=1,gray!60,thin] (0,0) grid (" + size + "," + size + ");\n")
+	bw.WriteString("  \\draw[thick] (0,0) rectangle (" + size + "," + size + ");\n")
+
+	for l := range b.Board {
+		for c, cell := range b.Board[l] {
+			style := "font=\\bfseries"
+			if !cell.Defined {
+				if opts.Solution == nil || opts.Solution.Size != b.Size ||
+					!opts.Solution.Board[l][c].Defined {
+					continue
+				}
+				cell = opts.Solution.Board[l][c]
+				style = "gray"
+			}
+			bw.WriteString("  \\node[" + style + "] at (" + f(float64(c)+0.5) + "," +
+				f(float64(l)+0.5) + ") {" + strconv.Itoa(cell.Value) + "};\n")
+		}
+	}
+	bw.WriteString("\\end{tikzpicture}\n")
+
+	if opts.Standalone {
+		bw.WriteString("\\end{document}\n")
+	}
+
+	return bw.Flush()
+}
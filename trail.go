@@ -0,0 +1,484 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+// This file contains the trail-based solver.
+// The board is solved in place: the assignments are recorded on a trail
+// and undone on backtrack, instead of cloning the board for each guess.
+// The bitmasks of the 0s and of the 1s of each line and column are
+// maintained incrementally, so that the rules are checked in constant time
+// when a cell is set.
+
+import (
+	"context"
+	"math/bits"
+
+	"github.com/pkg/errors"
+)
+
+// MaxTrailSolverSize is the largest board size supported by the trail-based
+// solver
+const MaxTrailSolverSize = 64
+
+// trailCheckInterval is the number of search nodes between two checks of
+// the context and the timeout
+const trailCheckInterval = 1024
+
+type trailSolver struct {
+	size, half int
+	mask       uint64 // Bitmask of the cells of a range
+	cells      []int8 // Cell values, -1 for empty cells
+
+	// Ranges: lines are 0..size-1, columns are size..2*size-1
+	vals [2][]uint64       // Bitmasks of the 0s and of the 1s of each range
+	full [2]map[uint64]int // Complete lines and complete columns
+
+	fw, bw []uint64 // Buffers used by completions
+
+	trail   []int // Assigned cells
+	queue   []int // Ranges to revise
+	inQueue []bool
+
+	rng randSource // Random order of the values, if not nil
+}
+
+func newTrailSolver(size int) *trailSolver {
+	s := &trailSolver{
+		size:    size,
+		half:    size / 2,
+		mask:    1<<uint(size) - 1,
+		cells:   make([]int8, size*size),
+		inQueue: make([]bool, 2*size),
+		fw:      make([]uint64, (size+1)*runStates),
+		bw:      make([]uint64, (size+1)*runStates),
+	}
+	if size == 64 {
+		s.mask = ^uint64(0)
+	}
+	for v := 0; v < 2; v++ {
+		s.vals[v] = make([]uint64, 2*size)
+		s.full[v] = make(map[uint64]int)
+	}
+	for i := range s.cells {
+		s.cells[i] = -1
+	}
+	return s
+}
+
+// cell returns the index of the k-th cell of the range r
+func (s *trailSolver) cell(r, k int) int {
+	if r < s.size {
+		return r*s.size + k
+	}
+	return k*s.size + r - s.size
+}
+
+// empty returns the bitmask of the empty cells of the range r
+func (s *trailSolver) empty(r int) uint64 {
+	return s.mask &^ (s.vals[0][r] | s.vals[1][r])
+}
+
+// count returns the number of cells of the range r with the value v
+func (s *trailSolver) count(v int8, r int) int {
+	return bits.OnesCount64(s.vals[v][r])
+}
+
+// orientation returns 0 for lines and 1 for columns
+func (s *trailSolver) orientation(r int) int {
+	return r / s.size
+}
+
+// assign sets the cell i to v and records it on the trail.  It returns
+// false if a rule is broken; the assignment must be undone in this case.
+func (s *trailSolver) assign(i int, v int8) bool {
+	l, c := i/s.size, i%s.size
+	s.cells[i] = v
+	s.trail = append(s.trail, i)
+
+	ok := true
+	for _, rk := range [2][2]int{{l, c}, {s.size + c, l}} {
+		r, k := rk[0], rk[1]
+		s.vals[v][r] |= 1 << uint(k)
+		if !s.inQueue[r] {
+			s.inQueue[r] = true
+			s.queue = append(s.queue, r)
+		}
+
+		m := s.vals[v][r]
+		if bits.OnesCount64(m) > s.half {
+			ok = false // Unbalanced range
+		}
+		if m&(m>>1)&(m>>2) != 0 {
+			ok = false // Three identical values in a row
+		}
+		if s.empty(r) == 0 {
+			o := s.orientation(r)
+			s.full[o][s.vals[1][r]]++
+			if s.full[o][s.vals[1][r]] > 1 {
+				ok = false // Duplicate range
+			}
+		}
+	}
+	return ok
+}
+
+// undo undoes the assignments of the trail down to the given length
+func (s *trailSolver) undo(mark int) {
+	for len(s.trail) > mark {
+		i := s.trail[len(s.trail)-1]
+		s.trail = s.trail[:len(s.trail)-1]
+		l, c := i/s.size, i%s.size
+		v := s.cells[i]
+		for _, rk := range [2][2]int{{l, c}, {s.size + c, l}} {
+			r, k := rk[0], rk[1]
+			if s.empty(r) == 0 {
+				o := s.orientation(r)
+				if s.full[o][s.vals[1][r]]--; s.full[o][s.vals[1][r]] == 0 {
+					delete(s.full[o], s.vals[1][r])
+				}
+			}
+			s.vals[v][r] &^= 1 << uint(k)
+		}
+		s.cells[i] = -1
+	}
+	for _, r := range s.queue {
+		s.inQueue[r] = false
+	}
+	s.queue = s.queue[:0]
+}
+
+// assignMask sets to v the cells of the range r given by the bitmask m.
+// It returns false if a rule is broken.
+func (s *trailSolver) assignMask(r int, m uint64, v int8) bool {
+	for ; m != 0; m &= m - 1 {
+		if !s.assign(s.cell(r, bits.TrailingZeros64(m)), v) {
+			return false
+		}
+	}
+	return true
+}
+
+// revise sets the cells of the range r that can be deduced from the rules
+// on this range.  It returns false if a rule is broken.
+// The range is queued again when a cell is set, so one deduction step is
+// enough.
+func (s *trailSolver) revise(r int) bool {
+	empty := s.empty(r)
+	if empty == 0 {
+		return true
+	}
+	for v := int8(0); v < 2; v++ {
+		if s.count(v, r) == s.half {
+			// Balance: the other cells get the other value
+			return s.assignMask(r, empty, 1-v)
+		}
+	}
+
+	// Pairs and gaps: the cells next to two identical values get the
+	// other value
+	var forced [2]uint64
+	for v := int8(0); v < 2; v++ {
+		m := s.vals[v][r]
+		forced[1-v] = ((m>>1)&(m>>2) | (m<<1)&(m<<2) | (m<<1)&(m>>1)) & empty
+	}
+	if forced[0]&forced[1] != 0 {
+		return false
+	}
+	if forced[0]|forced[1] != 0 {
+		return s.assignMask(r, forced[0], 0) && s.assignMask(r, forced[1], 1)
+	}
+
+	// Line completions: the cells that have the same value in all the
+	// valid completions of the range
+	can0, can1 := s.completions(r)
+	if empty&^(can0|can1) != 0 {
+		return false
+	}
+	if m0, m1 := empty&^can1, empty&^can0; m0|m1 != 0 {
+		return s.assignMask(r, m0, 0) && s.assignMask(r, m1, 1)
+	}
+
+	// Duplicates: with one 0 and one 1 missing, a completion cannot be a
+	// complete range
+	if s.count(0, r) == s.half-1 && s.count(1, r) == s.half-1 {
+		e0 := uint64(1) << uint(bits.TrailingZeros64(empty))
+		e1 := empty &^ e0
+		full := s.full[s.orientation(r)]
+		if full[s.vals[1][r]|e0] > 0 {
+			return s.assignMask(r, e0, 0) && s.assignMask(r, e1, 1)
+		}
+		if full[s.vals[1][r]|e1] > 0 {
+			return s.assignMask(r, e0, 1) && s.assignMask(r, e1, 0)
+		}
+	}
+	return true
+}
+
+// Run states used by completions: start of the range, then the last value
+// and the length of its run
+const (
+	runStart = iota
+	run0x1
+	run0x2
+	run1x1
+	run1x2
+	runStates
+)
+
+// runNext returns the run state after the value v, or -1 if there would be
+// three identical values in a row
+func runNext(st int, v int8) int {
+	switch {
+	case v == 0 && st == run0x2, v == 1 && st == run1x2:
+		return -1
+	case v == 0 && st == run0x1:
+		return run0x2
+	case v == 0:
+		return run0x1
+	case st == run1x1:
+		return run1x2
+	}
+	return run1x1
+}
+
+// completions returns the bitmasks of the cells of the range r that can
+// get a 0 and of the cells that can get a 1 in a completion of the range
+// following the balance and the adjacency rules.
+// The reachable states are computed forwards and backwards; they are sets
+// of numbers of 1s, stored as bitmasks.
+func (s *trailSolver) completions(r int) (can0, can1 uint64) {
+	n := s.size
+	fw, bw := s.fw, s.bw
+	for i := range fw {
+		fw[i], bw[i] = 0, 0
+	}
+	// ok0(k) is the set of the numbers of 1s allowed after k cells
+	ok0 := func(k int) uint64 {
+		if k <= s.half {
+			return ^uint64(0)
+		}
+		return ^(uint64(1)<<uint(k-s.half) - 1)
+	}
+	allowed := func(k int, v int8) bool {
+		return s.vals[1-v][r]&(1<<uint(k)) == 0
+	}
+
+	fw[runStart] = 1
+	for k := 0; k < n; k++ {
+		for st := 0; st < runStates; st++ {
+			m := fw[k*runStates+st]
+			if m == 0 {
+				continue
+			}
+			for v := int8(0); v < 2; v++ {
+				ns := runNext(st, v)
+				if ns < 0 || !allowed(k, v) {
+					continue
+				}
+				if v == 0 {
+					fw[(k+1)*runStates+ns] |= m & ok0(k+1)
+				} else {
+					fw[(k+1)*runStates+ns] |= m << 1
+				}
+			}
+		}
+	}
+
+	for st := 0; st < runStates; st++ {
+		bw[n*runStates+st] = 1 << uint(s.half)
+	}
+	for k := n - 1; k >= 0; k-- {
+		for st := 0; st < runStates; st++ {
+			f := fw[k*runStates+st]
+			for v := int8(0); v < 2; v++ {
+				ns := runNext(st, v)
+				if ns < 0 || !allowed(k, v) {
+					continue
+				}
+				b := bw[(k+1)*runStates+ns]
+				if v == 0 {
+					b &= ok0(k + 1)
+				} else {
+					b >>= 1
+				}
+				bw[k*runStates+st] |= b
+				if f&b != 0 {
+					if v == 0 {
+						can0 |= 1 << uint(k)
+					} else {
+						can1 |= 1 << uint(k)
+					}
+				}
+			}
+		}
+	}
+	return can0, can1
+}
+
+// propagate revises the ranges of the queue until no more cells can be
+// deduced.  It returns false if a rule is broken.
+func (s *trailSolver) propagate() bool {
+	for len(s.queue) > 0 {
+		r := s.queue[len(s.queue)-1]
+		s.queue = s.queue[:len(s.queue)-1]
+		s.inQueue[r] = false
+		if !s.revise(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// pick returns the empty cell of the line and the column with the fewest
+// empty cells, or -1 if the board is complete
+func (s *trailSolver) pick() int {
+	best, bestEmpty := -1, 2*s.size+1
+	for l := 0; l < s.size; l++ {
+		el := s.empty(l)
+		if el == 0 {
+			continue
+		}
+		nl := bits.OnesCount64(el)
+		for m := el; m != 0; m &= m - 1 {
+			c := bits.TrailingZeros64(m)
+			if e := nl + bits.OnesCount64(s.empty(s.size+c)); e < bestEmpty {
+				best, bestEmpty = l*s.size+c, e
+			}
+		}
+	}
+	return best
+}
+
+// search enumerates the solutions.  The visit function is called for each
+// solution; the search stops if it returns false.  The stop function is
+// called regularly; the search is aborted if it returns an error.
+func (s *trailSolver) search(visit func() bool, stop func() error) error {
+	var nodes int
+	var err error
+	var dfs func() bool
+	dfs = func() bool {
+		nodes++
+		if nodes%trailCheckInterval == 0 {
+			if err = stop(); err != nil {
+				return false
+			}
+		}
+		i := s.pick()
+		if i < 0 {
+			return visit()
+		}
+		first := int8(0)
+		if s.rng != nil {
+			first = int8(s.rng.Intn(2))
+		}
+		for n := int8(0); n < 2; n++ {
+			v := first ^ n
+			mark := len(s.trail)
+			if s.assign(i, v) && s.propagate() {
+				if !dfs() {
+					s.undo(mark)
+					return false
+				}
+			}
+			s.undo(mark)
+		}
+		return true
+	}
+
+	if !s.propagate() {
+		return nil
+	}
+	dfs()
+	return err
+}
+
+// trailRandomSolutionNodes is the number of search nodes tried by
+// trailRandomSolution before restarting from an empty board
+const trailRandomSolutionNodes = 64 * trailCheckInterval
+
+// trailRandomSolution builds a random complete board with the trail-based
+// solver, trying the values in random order.  The search is restarted when
+// it gets stuck.
+// It returns nil if the size is not supported or if the context is canceled.
+func trailRandomSolution(ctx context.Context, size int, rng randSource) *Takuzu {
+	if size%2 != 0 || size > MaxTrailSolverSize {
+		return nil
+	}
+	for ctx.Err() == nil {
+		s := newTrailSolver(size)
+		s.rng = rng
+		var tak *Takuzu
+		var nodes int
+		visit := func() bool {
+			t := New(size)
+			for i, v := range s.cells {
+				t.Board[i/size][i%size].Set(int(v))
+			}
+			tak = &t
+			return false
+		}
+		stop := func() error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if nodes += trailCheckInterval; nodes > trailRandomSolutionNodes {
+				return errors.New("restart")
+			}
+			return nil
+		}
+		if s.search(visit, stop); tak != nil {
+			return tak
+		}
+	}
+	return nil
+}
+
+// TrySolveTrail solves the takuzu in place with backtracking on a trail of
+// assignments.  It follows the semantics of TrySolveWithOptions: if
+// allSolutions is not nil, all the solutions (up to opts.MaxSolutions) are
+// looked for.  The Propagate and Heuristic options are ignored, and the
+// board is not updated.  The board size must not be larger than
+// MaxTrailSolverSize.
+func (b Takuzu) TrySolveTrail(allSolutions *[]Takuzu, opts SolveOptions) (*Takuzu, error) {
+	if b.Size%2 != 0 || b.Size > MaxTrailSolverSize {
+		return nil, errors.Errorf("unsupported board size %d", b.Size)
+	}
+
+	s := newTrailSolver(b.Size)
+	consistent := true
+	for l := range b.Board {
+		for c, cell := range b.Board[l] {
+			if cell.Defined && !s.assign(l*b.Size+c, int8(cell.Value)) {
+				consistent = false
+			}
+		}
+	}
+	if !consistent {
+		return nil, ErrNoSolution
+	}
+
+	var first *Takuzu
+	visit := func() bool {
+		tak := New(b.Size)
+		for i, v := range s.cells {
+			tak.Board[i/b.Size][i%b.Size].Set(int(v))
+		}
+		if first == nil {
+			first = &tak
+		}
+		if allSolutions == nil {
+			return false
+		}
+		*allSolutions = append(*allSolutions, tak)
+		return opts.MaxSolutions <= 0 || len(*allSolutions) < opts.MaxSolutions
+	}
+	if err := s.search(visit, assembleStop(opts)); err != nil {
+		return first, err
+	}
+	if first == nil {
+		return nil, ErrNoSolution
+	}
+	return first, nil
+}
@@ -0,0 +1,90 @@
+// Copyright (C) 2016 Mikael Berthe <mikael@lilotux.net>. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// which can be found in the LICENSE file.
+
+package takuzu
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestTrailMatchesRecurse(t *testing.T) {
+	for _, b := range testBoards(t) {
+		want := &[]Takuzu{}
+		b.Clone().TrySolveWithOptions(want, SolveOptions{})
+		wantStrings := solutionStrings(*want)
+
+		got := &[]Takuzu{}
+		first, err := b.TrySolveTrail(got, SolveOptions{})
+		gotStrings := solutionStrings(*got)
+		if !reflect.DeepEqual(gotStrings, wantStrings) {
+			t.Errorf("%s: TrySolveTrail found %d solutions, want %d",
+				b.ToString(), len(gotStrings), len(wantStrings))
+			continue
+		}
+		if len(wantStrings) == 0 && (errors.Cause(err) != ErrNoSolution || first != nil) {
+			t.Errorf("%s: TrySolveTrail returned %v, want %v", b.ToString(), err, ErrNoSolution)
+		}
+		if len(wantStrings) > 0 && (err != nil || first == nil) {
+			t.Errorf("%s: TrySolveTrail: %v", b.ToString(), err)
+		}
+
+		// Single solution
+		sol, err := b.TrySolveTrail(nil, SolveOptions{})
+		if len(wantStrings) == 0 && errors.Cause(err) != ErrNoSolution {
+			t.Errorf("%s: TrySolveTrail returned %v, want %v", b.ToString(), err, ErrNoSolution)
+		}
+		if len(wantStrings) > 0 && (err != nil || sol == nil || len(*want) == 1 && sol.ToString() != wantStrings[0]) {
+			t.Errorf("%s: TrySolveTrail returned %v (%v)", b.ToString(), sol, err)
+		}
+
+		// Limited search
+		if len(wantStrings) > 1 {
+			got = &[]Takuzu{}
+			if _, err := b.TrySolveTrail(got, SolveOptions{MaxSolutions: 2}); err != nil || len(*got) != 2 {
+				t.Errorf("%s: TrySolveTrail found %d solutions (%v), want 2",
+					b.ToString(), len(*got), err)
+			}
+		}
+	}
+
+	for _, size := range []int{5, MaxTrailSolverSize + 2} {
+		if _, err := New(size).TrySolveTrail(nil, SolveOptions{}); err == nil {
+			t.Errorf("no error for the size %d", size)
+		}
+	}
+}
+
+func TestTrailRandomSolution(t *testing.T) {
+	ctx := context.Background()
+	for _, size := range []int{4, 6, 8, 12, 20, 30} {
+		tak := trailRandomSolution(ctx, size, rand.New(rand.NewSource(1)))
+		if tak == nil {
+			t.Errorf("size %d: no board", size)
+			continue
+		}
+		if full, err := tak.Validate(); !full || err != nil {
+			t.Errorf("size %d: invalid board %s (%v)", size, tak.ToString(), err)
+		}
+
+		// The same seed builds the same board
+		again := trailRandomSolution(ctx, size, rand.New(rand.NewSource(1)))
+		if again == nil || again.ToString() != tak.ToString() {
+			t.Errorf("size %d: the boards of the same seed differ", size)
+		}
+	}
+
+	if trailRandomSolution(ctx, 5, rand.New(rand.NewSource(1))) != nil {
+		t.Error("board returned for an odd size")
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if trailRandomSolution(canceled, 8, rand.New(rand.NewSource(1))) != nil {
+		t.Error("board returned with a canceled context")
+	}
+}
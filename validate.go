@@ -94,31 +94,37 @@ func (b Takuzu) CheckColumn(i int) error {
 func (b Takuzu) Validate() (bool, error) {
 	finished := true
 
-	computeVal := func(cells []Cell) (val int) {
-		for i := 0; i < len(cells); i++ {
-			val += cells[i].Value * 1 << uint(i)
+	// The complete ranges are looked up in the table of the valid lines
+	// when it is available; the other ranges are fully checked.
+	hasTable := LinePatterns(b.Size) != nil
+	all := uint64(1)<<uint(b.Size) - 1
+	check := func(cells []Cell) (full bool, bits uint64, err error) {
+		bits, defined := rangeBits(cells)
+		if hasTable && defined == all {
+			if valid, _ := IsValidLine(b.Size, bits); valid {
+				return true, bits, nil
+			}
 		}
-		return
+		full, err = checkRange(cells)
+		return full, bits, err
 	}
 
-	lineVals := make(map[int]bool)
-	colVals := make(map[int]bool)
+	lineVals := make(map[uint64]bool)
+	colVals := make(map[uint64]bool)
 
 	for i := 0; i < b.Size; i++ {
-		var d []Cell
 		var full bool
+		var hv uint64
 		var err error
 
 		// Let's check line i
-		d = b.GetLine(i)
-		full, err = checkRange(d)
+		full, hv, err = check(b.GetLine(i))
 		if err != nil {
 			err := err.(validationError)
 			err.LineNumber = &i
 			return false, err
 		}
 		if full {
-			hv := computeVal(d)
 			if lineVals[hv] {
 				err := validationError{
 					ErrorType:  ErrorDuplicate,
@@ -132,15 +138,13 @@ func (b Takuzu) Validate() (bool, error) {
 		}
 
 		// Let's check column i
-		d = b.GetColumn(i)
-		full, err = checkRange(d)
+		full, hv, err = check(b.GetColumn(i))
 		if err != nil {
 			err := err.(validationError)
 			err.ColumnNumber = &i
 			return false, err
 		}
 		if full {
-			hv := computeVal(d)
 			if colVals[hv] {
 				err := validationError{
 					ErrorType:    ErrorDuplicate,
@@ -155,3 +159,85 @@ func (b Takuzu) Validate() (bool, error) {
 	}
 	return finished, nil
 }
+
+// ErrorCells returns the positions of the cells breaking the rules, in
+// row-major order.
+func (b Takuzu) ErrorCells() []Position {
+	bad := make([][]bool, b.Size)
+	for i := range bad {
+		bad[i] = make([]bool, b.Size)
+	}
+
+	// markRange flags the cells of a range (line or column) that break the
+	// rules; pos returns the board position of the kth cell of the range.
+	markRange := func(cells []Cell, pos func(k int) (int, int)) {
+		var n [2]int
+		for _, c := range cells {
+			if c.Defined {
+				n[c.Value]++
+			}
+		}
+		for k, c := range cells {
+			if !c.Defined {
+				continue
+			}
+			if n[c.Value] > len(cells)/2 {
+				l, col := pos(k)
+				bad[l][col] = true
+			}
+			if k >= 2 && cells[k-1].Defined && cells[k-2].Defined &&
+				cells[k-1].Value == c.Value && cells[k-2].Value == c.Value {
+				for j := k - 2; j <= k; j++ {
+					l, col := pos(j)
+					bad[l][col] = true
+				}
+			}
+		}
+	}
+
+	// markDuplicates flags all the cells of identical full ranges
+	markDuplicates := func(ranges [][]Cell, pos func(i, k int) (int, int)) {
+		seen := make(map[string]int)
+		for i, r := range ranges {
+			if full, _, _ := CheckRangeCounts(r); !full {
+				continue
+			}
+			key := make([]byte, len(r))
+			for k, c := range r {
+				key[k] = byte('0' + c.Value)
+			}
+			if j, ok := seen[string(key)]; ok {
+				for k := range r {
+					l, c := pos(i, k)
+					bad[l][c] = true
+					l, c = pos(j, k)
+					bad[l][c] = true
+				}
+				continue
+			}
+			seen[string(key)] = i
+		}
+	}
+
+	lines := make([][]Cell, b.Size)
+	columns := make([][]Cell, b.Size)
+	for i := 0; i < b.Size; i++ {
+		i := i
+		lines[i] = b.GetLine(i)
+		columns[i] = b.GetColumn(i)
+		markRange(lines[i], func(k int) (int, int) { return i, k })
+		markRange(columns[i], func(k int) (int, int) { return k, i })
+	}
+	markDuplicates(lines, func(i, k int) (int, int) { return i, k })
+	markDuplicates(columns, func(i, k int) (int, int) { return k, i })
+
+	var res []Position
+	for l := range bad {
+		for c := range bad[l] {
+			if bad[l][c] {
+				res = append(res, Position{Line: l, Col: c})
+			}
+		}
+	}
+	return res
+}